@@ -4,136 +4,342 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"serge.com/mcp-example/api/gigachat"
+	"serge.com/mcp-example/llm"
 	"serge.com/mcp-example/mcp_client"
+	"strings"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+// DefaultMaxToolRounds is the number of tool rounds allowed per user turn
+const DefaultMaxToolRounds = 10
+
+// MaxToolRoundsError is returned when the model keeps calling tools after
+// the allowed number of tool rounds
+type MaxToolRoundsError struct {
+	Rounds int
+}
+
+func (e *MaxToolRoundsError) Error() string {
+	return fmt.Sprintf("model requested more than %d tool rounds", e.Rounds)
+}
+
+// ToolErrorPolicy decides what happens when an MCP tool call fails
+type ToolErrorPolicy int
+
+const (
+	// ToolErrorsReportToModel sends the error text back to the model as the
+	// tool result, so it can retry with different arguments or explain the failure
+	ToolErrorsReportToModel ToolErrorPolicy = iota
+	// ToolErrorsFailFast aborts the turn with an error
+	ToolErrorsFailFast
+)
+
 type Agent struct {
-	Model                  string
-	temperature            float64
-	messages               []gigachat.Message
-	gigaChatNetworkService *gigachat.NetworkService
-	mcpClient              *mcpclient.MCPClient
-	functions              []gigachat.Function
+	Model         string
+	TurnTimeout   time.Duration // Deadline for a whole SendMessage turn, zero means none
+	MaxToolRounds int           // Tool rounds allowed per user turn
+	// ForceFinalAnswer disables tools for the completion that follows the last
+	// allowed tool round, so the model has to answer with text
+	ForceFinalAnswer bool
+	ToolErrorPolicy  ToolErrorPolicy
+	temperature      float64
+	messages         []llm.Message
+	provider         llm.Provider
+	mcpClients       []*mcpclient.MCPClient
+	tools            []llm.Tool
+	toolRoutes       map[string]toolRoute // Tool name seen by the model -> owning server
+	toolGenerations  []int                // Session generation of each client when its tools were listed
 }
 
-func NewAgent(gigaChatNetworkService *gigachat.NetworkService, mcpClient *mcpclient.MCPClient) (*Agent, error) {
+// toolRoute tells which MCP server owns a tool and its name on that server
+type toolRoute struct {
+	mcpClient *mcpclient.MCPClient
+	toolName  string
+}
+
+// toolNameSeparator joins server and tool names
+const toolNameSeparator = "__"
+
+// NewAgent creates an agent that uses the tools of every given MCP server.
+// Each tool is exposed to the model as "<server>__<tool>", so tool names stay
+// the same when servers are added or removed.
+func NewAgent(ctx context.Context, provider llm.Provider, mcpClients ...*mcpclient.MCPClient) (*Agent, error) {
 	agent := &Agent{
-		Model:                  "GigaChat-2",
-		temperature:            0.0,
-		messages:               []gigachat.Message{},
-		gigaChatNetworkService: gigaChatNetworkService,
-		mcpClient:              mcpClient,
-		functions:              []gigachat.Function{},
+		Model:         "",
+		MaxToolRounds: DefaultMaxToolRounds,
+		temperature:   0.0,
+		messages:      []llm.Message{},
+		provider:      provider,
+		mcpClients:    mcpClients,
 	}
 
-	// Get tools from MCP and convert to GigaChat functions
-	if err := agent.loadMCPTools(); err != nil {
+	// Get tools from MCP and convert to provider-neutral tools
+	if err := agent.loadMCPTools(ctx); err != nil {
 		return nil, fmt.Errorf("failed to load MCP tools: %w", err)
 	}
 
 	return agent, nil
 }
 
-func (a *Agent) loadMCPTools() error {
-	tools, err := a.mcpClient.CallToolsList()
-	if err != nil {
-		return err
-	}
+// loadMCPTools lists the tools of every server and replaces the ones known
+// to the model
+func (a *Agent) loadMCPTools(ctx context.Context) error {
+	serverNames := map[string]bool{}
+	tools := []llm.Tool{}
+	toolRoutes := map[string]toolRoute{}
+	generations := make([]int, len(a.mcpClients))
 
-	for _, tool := range tools {
-		// Parse InputSchema from JSON string to object
-		var schema any
-		if tool.InputSchema != "" {
-			if err := json.Unmarshal([]byte(tool.InputSchema), &schema); err != nil {
-				return fmt.Errorf("failed to parse schema for tool %s: %w", tool.Name, err)
-			}
+	for i, mcpClient := range a.mcpClients {
+		serverName := sanitizeToolName(mcpClient.Name())
+		if serverNames[serverName] {
+			return fmt.Errorf("duplicate MCP server name %q", serverName)
 		}
+		serverNames[serverName] = true
 
-		a.functions = append(a.functions, gigachat.Function{
-			Name:        tool.Name,
-			Description: tool.Description,
-			Parameters:  schema,
-		})
+		// Read before listing, so a reconnect during the listing is noticed
+		generations[i] = mcpClient.Generation()
+		serverTools, err := mcpClient.CallToolsList(ctx)
+		if err != nil {
+			return fmt.Errorf("server %s: %w", mcpClient.Name(), err)
+		}
+
+		for _, tool := range serverTools {
+			// Parse InputSchema from JSON string to object
+			var schema any
+			if tool.InputSchema != "" {
+				if err := json.Unmarshal([]byte(tool.InputSchema), &schema); err != nil {
+					return fmt.Errorf("failed to parse schema for tool %s: %w", tool.Name, err)
+				}
+			}
+
+			name := serverName + toolNameSeparator + tool.Name
+			if _, exists := toolRoutes[name]; exists {
+				return fmt.Errorf("duplicate tool name %q", name)
+			}
+			toolRoutes[name] = toolRoute{mcpClient: mcpClient, toolName: tool.Name}
+
+			tools = append(tools, llm.Tool{
+				Name:        name,
+				Description: tool.Description,
+				Parameters:  schema,
+			})
+		}
 	}
 
+	a.tools, a.toolRoutes, a.toolGenerations = tools, toolRoutes, generations
 	return nil
 }
 
+// refreshMCPTools lists the tools again if a server was reconnected since
+// they were loaded, as the restarted server may offer different ones
+func (a *Agent) refreshMCPTools(ctx context.Context) error {
+	for i, mcpClient := range a.mcpClients {
+		if mcpClient.Generation() != a.toolGenerations[i] {
+			if err := a.loadMCPTools(ctx); err != nil {
+				return fmt.Errorf("failed to reload MCP tools: %w", err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// sanitizeToolName replaces characters that model APIs don't accept in
+// function names
+func sanitizeToolName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
 func (a *Agent) SetContext(agentContext string) {
-	agentContextMessage := gigachat.Message{
-		Role:    "system",
+	agentContextMessage := llm.Message{
+		Role:    llm.RoleSystem,
 		Content: agentContext,
 	}
 
 	a.messages = append(a.messages, agentContextMessage)
 }
 
-func (a *Agent) SendMessage(message string) (string, error) {
-	a.messages = append(a.messages, gigachat.Message{
-		Role:    "user",
-		Content: message,
-	})
+// AttachResource reads an MCP resource, such as a file:// URI, and adds its
+// text to the conversation, so the model sees it without a tool call. The
+// first server that can read the URI is used.
+func (a *Agent) AttachResource(ctx context.Context, uri string) error {
+	err := fmt.Errorf("no MCP server to read %s", uri)
+
+	for _, mcpClient := range a.mcpClients {
+		var result *mcp.ReadResourceResult
+		result, err = mcpClient.ReadResource(ctx, uri)
+		if err != nil {
+			continue
+		}
+
+		var text strings.Builder
+		for _, contents := range result.Contents {
+			if contents.Blob != nil {
+				return fmt.Errorf("resource %s is binary (%s)", uri, contents.MIMEType)
+			}
+			text.WriteString(contents.Text)
+		}
+
+		a.messages = append(a.messages, llm.Message{
+			Role:    llm.RoleUser,
+			Content: fmt.Sprintf("Contents of %s:\n\n%s", uri, text.String()),
+		})
+		return nil
+	}
 
-	return a.processCompletion()
+	return err
 }
 
-func (a *Agent) processCompletion() (string, error) {
-	// Call GigaChat with functions
-	result, err := a.gigaChatNetworkService.GetCompletion(a.messages, a.Model, a.temperature, a.functions)
+func (a *Agent) SendMessage(ctx context.Context, message string) (string, error) {
+	return a.runTurn(ctx, message, nil)
+}
+
+// SendMessageStream is like SendMessage but passes generated text to onDelta
+// as it arrives. Tool calls are handled the same way as in SendMessage.
+// Providers without streaming support deliver each answer in a single delta.
+func (a *Agent) SendMessageStream(ctx context.Context, message string, onDelta func(delta string)) (string, error) {
+	return a.runTurn(ctx, message, onDelta)
+}
+
+// runTurn processes one user message. If the turn fails or ctx is cancelled,
+// every message added during the turn is dropped so the history never ends
+// with an unanswered user message or tool call.
+func (a *Agent) runTurn(ctx context.Context, message string, onDelta func(delta string)) (string, error) {
+	if a.TurnTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.TurnTimeout)
+		defer cancel()
+	}
+
+	historyLength := len(a.messages)
+	a.messages = append(a.messages, llm.Message{
+		Role:    llm.RoleUser,
+		Content: message,
+	})
+
+	answer, err := a.processCompletion(ctx, onDelta)
 	if err != nil {
-		return "", fmt.Errorf("failed to get answer: %w", err)
+		a.messages = a.messages[:historyLength]
+		return "", err
 	}
 
-	// Check if GigaChat wants to call a function
-	if result.FinishReason == "function_call" && result.FunctionCall != nil {
-		fmt.Printf("\n[Agent] Using MCP tool: %s\n", result.FunctionCall.Name)
-		fmt.Printf("[Agent] Tool arguments: %v\n", result.FunctionCall.Arguments)
+	return answer, nil
+}
+
+func (a *Agent) processCompletion(ctx context.Context, onDelta func(delta string)) (string, error) {
+	for round := 0; ; round++ {
+		// Call the model with tools, or without them once the rounds are used up
+		toolChoice := llm.ToolChoiceAuto
+		if a.ForceFinalAnswer && round >= a.MaxToolRounds {
+			toolChoice = llm.ToolChoiceNone
+		}
 
-		// Call MCP tool
-		toolResult, err := a.callMCPTool(result.FunctionCall)
+		if err := a.refreshMCPTools(ctx); err != nil {
+			return "", err
+		}
+
+		result, err := a.complete(ctx, toolChoice, onDelta)
 		if err != nil {
-			return "", fmt.Errorf("failed to call MCP tool: %w", err)
+			return "", fmt.Errorf("failed to get answer: %w", err)
 		}
 
-		fmt.Printf("[Agent] Tool result: %s\n", toolResult)
+		// No tool call - we have the final answer
+		if result.FinishReason != llm.FinishReasonToolCalls || len(result.Message.ToolCalls) == 0 {
+			fmt.Printf("\n[Agent] No MCP tool used - direct response\n\n")
+			a.messages = append(a.messages, result.Message)
+			return result.Message.Content, nil
+		}
 
-		// Add assistant's function call to messages
-		a.messages = append(a.messages, *result.Message)
+		if round >= a.MaxToolRounds {
+			return "", &MaxToolRoundsError{Rounds: a.MaxToolRounds}
+		}
 
-		// Add function result as function message with proper format
-		// GigaChat expects: {"name": "function_name", "arguments": {"result": "..."}}
-		// JSON-encode the tool result to ensure proper escaping
-		resultJSON, err := json.Marshal(toolResult)
+		if err := a.runToolCalls(ctx, result.Message); err != nil {
+			return "", err
+		}
+	}
+}
+
+// runToolCalls calls every tool the model asked for and adds the assistant
+// message together with the tool results to the history
+func (a *Agent) runToolCalls(ctx context.Context, assistantMessage llm.Message) error {
+	toolMessages := make([]llm.Message, 0, len(assistantMessage.ToolCalls))
+
+	for _, toolCall := range assistantMessage.ToolCalls {
+		fmt.Printf("\n[Agent] Using MCP tool: %s\n", toolCall.Name)
+		fmt.Printf("[Agent] Tool arguments: %v\n", toolCall.Arguments)
+
+		// Call MCP tool
+		toolResult, err := a.callMCPTool(ctx, toolCall)
 		if err != nil {
-			return "", fmt.Errorf("failed to marshal tool result: %w", err)
+			// Cancellation is never the model's fault, so it always ends the turn
+			if a.ToolErrorPolicy == ToolErrorsFailFast || ctx.Err() != nil {
+				return fmt.Errorf("failed to call MCP tool: %w", err)
+			}
+			fmt.Printf("[Agent] Tool error reported to model: %v\n", err)
+			toolResult = fmt.Sprintf("Error: %v", err)
 		}
-		functionResultContent := fmt.Sprintf(`{"name": "%s", "arguments": %s}`, result.FunctionCall.Name, string(resultJSON))
 
-		a.messages = append(a.messages, gigachat.Message{
-			Role:    "function",
-			Content: functionResultContent,
+		fmt.Printf("[Agent] Tool result: %s\n", toolResult)
+
+		toolMessages = append(toolMessages, llm.Message{
+			Role:       llm.RoleTool,
+			Content:    toolResult,
+			ToolCallID: toolCall.ID,
+			Name:       toolCall.Name,
 		})
+	}
+
+	// Add assistant's tool calls and their results to messages
+	a.messages = append(a.messages, assistantMessage)
+	a.messages = append(a.messages, toolMessages...)
 
-		// Recursive call - process the next completion
-		return a.processCompletion()
+	return nil
+}
+
+func (a *Agent) complete(ctx context.Context, toolChoice llm.ToolChoice, onDelta func(delta string)) (*llm.Completion, error) {
+	req := &llm.Request{
+		Model:       a.Model,
+		Temperature: a.temperature,
+		Messages:    a.messages,
+		Tools:       a.tools,
+		ToolChoice:  toolChoice,
+	}
+
+	if onDelta == nil {
+		return a.provider.Complete(ctx, req)
+	}
+
+	if streamingProvider, ok := a.provider.(llm.StreamingProvider); ok {
+		return streamingProvider.CompleteStream(ctx, req, onDelta)
 	}
 
-	// No function call - we have the final answer
-	fmt.Printf("\n[Agent] No MCP tool used - direct response\n\n")
-	a.messages = append(a.messages, *result.Message)
-	return result.Message.Content, nil
+	result, err := a.provider.Complete(ctx, req)
+	if err == nil && result.Message.Content != "" {
+		onDelta(result.Message.Content)
+	}
+	return result, err
 }
 
-func (a *Agent) callMCPTool(functionCall *gigachat.FunctionCall) (string, error) {
+func (a *Agent) callMCPTool(ctx context.Context, toolCall llm.ToolCall) (string, error) {
 	// Arguments are already parsed as map[string]any
-	args := functionCall.Arguments
+	args := toolCall.Arguments
+
+	// Find the server that owns the tool
+	route, ok := a.toolRoutes[toolCall.Name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %s", toolCall.Name)
+	}
 
 	// Call MCP tool
-	ctx := context.Background()
-	result, err := a.mcpClient.CallTool(ctx, functionCall.Name, args)
+	result, err := route.mcpClient.CallTool(ctx, route.toolName, args)
 	if err != nil {
 		return "", err
 	}
@@ -149,4 +355,4 @@ func (a *Agent) callMCPTool(functionCall *gigachat.FunctionCall) (string, error)
 	}
 
 	return textContent.Text, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,272 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"serge.com/mcp-example/llm"
+	"serge.com/mcp-example/llm/scripted"
+	"serge.com/mcp-example/mcp_client"
+)
+
+// serverPath is the mcp_server binary built for the tests
+var serverPath string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "agent-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	serverPath = filepath.Join(dir, "mcp_server")
+	build := exec.Command("go", "build", "-o", serverPath, "serge.com/mcp-example/mcp_server")
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to build mcp_server:", err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestAgent starts mcp_server with a fresh root directory and creates an
+// agent that talks to it through provider
+func newTestAgent(t *testing.T, provider llm.Provider) (*Agent, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	t.Setenv("MCP_FS_ROOTS", root)
+
+	client, err := mcpclient.NewMCPClient(context.Background(), serverPath, nil)
+	if err != nil {
+		t.Fatalf("NewMCPClient() error = %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	agent, err := NewAgent(context.Background(), provider, client)
+	if err != nil {
+		t.Fatalf("NewAgent() error = %v", err)
+	}
+	return agent, root
+}
+
+func greet(name string) *llm.Completion {
+	return scripted.ToolCall("greeter__greet", map[string]any{"name": name, "second_name": "Smith"})
+}
+
+func TestSendMessageToolCallSequence(t *testing.T) {
+	provider := scripted.NewProvider(
+		scripted.ToolCall("greeter__save_to_file", map[string]any{"filename": "note.txt", "text": "hello"}),
+		greet("Ann"),
+		scripted.Text("Saved and greeted"),
+	)
+	agent, root := newTestAgent(t, provider)
+
+	answer, err := agent.SendMessage(context.Background(), "Save a note and greet Ann")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if answer != "Saved and greeted" {
+		t.Errorf("SendMessage() = %q, want %q", answer, "Saved and greeted")
+	}
+
+	content, err := os.ReadFile(filepath.Join(root, "note.txt"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("note.txt = %q, %v; want %q", content, err, "hello")
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("provider got %d requests, want 3", len(requests))
+	}
+	if !hasTool(requests[0].Tools, "greeter__read_files") {
+		t.Errorf("tools sent to the model don't include greeter__read_files")
+	}
+
+	var roles []string
+	for _, message := range requests[2].Messages {
+		roles = append(roles, message.Role)
+	}
+	wantRoles := []string{llm.RoleUser, llm.RoleAssistant, llm.RoleTool, llm.RoleAssistant, llm.RoleTool}
+	if strings.Join(roles, ",") != strings.Join(wantRoles, ",") {
+		t.Errorf("roles of the last request = %v, want %v", roles, wantRoles)
+	}
+
+	results := provider.ToolResults()
+	if len(results) != 2 {
+		t.Fatalf("got %d tool results, want 2", len(results))
+	}
+	wantResults := []struct {
+		id       string
+		name     string
+		contains string
+	}{
+		{"call_1", "greeter__save_to_file", `"success":true`},
+		{"call_2", "greeter__greet", "Hi Ann Smith!"},
+	}
+	for i, want := range wantResults {
+		got := results[i]
+		if got.ToolCallID != want.id || got.Name != want.name || !strings.Contains(got.Content, want.contains) {
+			t.Errorf("tool result %d = %+v, want ID %s, name %s and content containing %q", i, got, want.id, want.name, want.contains)
+		}
+	}
+}
+
+func TestSendMessageMaxToolRounds(t *testing.T) {
+	provider := scripted.NewProvider(greet("A"), greet("B"), greet("C"))
+	agent, _ := newTestAgent(t, provider)
+	agent.MaxToolRounds = 2
+
+	_, err := agent.SendMessage(context.Background(), "Greet everyone")
+
+	var roundsErr *MaxToolRoundsError
+	if !errors.As(err, &roundsErr) || roundsErr.Rounds != 2 {
+		t.Fatalf("SendMessage() error = %v, want MaxToolRoundsError after 2 rounds", err)
+	}
+	if len(agent.messages) != 0 {
+		t.Errorf("history has %d messages after the failed turn, want 0", len(agent.messages))
+	}
+}
+
+func TestSendMessageForceFinalAnswer(t *testing.T) {
+	provider := scripted.NewProvider(greet("A"), scripted.Text("Done"))
+	agent, _ := newTestAgent(t, provider)
+	agent.MaxToolRounds = 1
+	agent.ForceFinalAnswer = true
+
+	answer, err := agent.SendMessage(context.Background(), "Greet A")
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if answer != "Done" {
+		t.Errorf("SendMessage() = %q, want %q", answer, "Done")
+	}
+
+	requests := provider.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("provider got %d requests, want 2", len(requests))
+	}
+	if requests[0].ToolChoice != llm.ToolChoiceAuto || requests[1].ToolChoice != llm.ToolChoiceNone {
+		t.Errorf("tool choices = %q, %q; want %q, %q", requests[0].ToolChoice, requests[1].ToolChoice, llm.ToolChoiceAuto, llm.ToolChoiceNone)
+	}
+}
+
+func TestToolErrorPolicy(t *testing.T) {
+	// Creating a file that already exists makes save_to_file fail
+	createNote := scripted.ToolCall("greeter__save_to_file", map[string]any{"filename": "note.txt", "text": "new", "mode": "create"})
+
+	t.Run("report to model", func(t *testing.T) {
+		provider := scripted.NewProvider(createNote, scripted.Text("The note already exists"))
+		agent, root := newTestAgent(t, provider)
+		writeFile(t, filepath.Join(root, "note.txt"), "old")
+
+		answer, err := agent.SendMessage(context.Background(), "Create a note")
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		if answer != "The note already exists" {
+			t.Errorf("SendMessage() = %q", answer)
+		}
+
+		results := provider.ToolResults()
+		if len(results) != 1 || !strings.HasPrefix(results[0].Content, "Error: ") || !strings.Contains(results[0].Content, "Failed to save file") {
+			t.Errorf("tool results = %+v, want the save_to_file error", results)
+		}
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		provider := scripted.NewProvider(createNote, scripted.Text("not reached"))
+		agent, root := newTestAgent(t, provider)
+		agent.ToolErrorPolicy = ToolErrorsFailFast
+		writeFile(t, filepath.Join(root, "note.txt"), "old")
+
+		_, err := agent.SendMessage(context.Background(), "Create a note")
+
+		var toolErr *mcpclient.ToolError
+		if !errors.As(err, &toolErr) || toolErr.ToolName != "save_to_file" {
+			t.Fatalf("SendMessage() error = %v, want a save_to_file ToolError", err)
+		}
+		if provider.Remaining() != 1 {
+			t.Errorf("model was asked again after the tool error")
+		}
+		if len(agent.messages) != 0 {
+			t.Errorf("history has %d messages after the failed turn, want 0", len(agent.messages))
+		}
+	})
+}
+
+// cancelingProvider cancels the turn when the model is asked for the
+// completion with index cancelAt
+type cancelingProvider struct {
+	*scripted.Provider
+	cancelAt int
+	cancel   context.CancelFunc
+	calls    int
+}
+
+func (p *cancelingProvider) Complete(ctx context.Context, req *llm.Request) (*llm.Completion, error) {
+	if p.calls == p.cancelAt {
+		p.cancel()
+	}
+	p.calls++
+	return p.Provider.Complete(ctx, req)
+}
+
+func TestSendMessageCancelRollsBackHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	provider := &cancelingProvider{
+		Provider: scripted.NewProvider(scripted.Text("Hello"), greet("A"), scripted.Text("not reached")),
+		cancelAt: 2, // After the tool round of the second turn
+		cancel:   cancel,
+	}
+	agent, _ := newTestAgent(t, provider)
+	agent.SetContext("You are a test")
+
+	if _, err := agent.SendMessage(ctx, "Hi"); err != nil {
+		t.Fatalf("first SendMessage() error = %v", err)
+	}
+	history := append([]llm.Message(nil), agent.messages...)
+
+	_, err := agent.SendMessage(ctx, "Greet A")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("second SendMessage() error = %v, want context.Canceled", err)
+	}
+
+	if len(agent.messages) != len(history) {
+		t.Fatalf("history has %d messages after the cancelled turn, want %d", len(agent.messages), len(history))
+	}
+	for i, message := range history {
+		if agent.messages[i].Role != message.Role || agent.messages[i].Content != message.Content {
+			t.Errorf("message %d = %+v, want %+v", i, agent.messages[i], message)
+		}
+	}
+}
+
+func hasTool(tools []llm.Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
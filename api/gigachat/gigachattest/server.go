@@ -0,0 +1,299 @@
+// Package gigachattest provides a local stand-in for the GigaChat API,
+// so NetworkService can be exercised with httptest and no network.
+package gigachattest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"serge.com/mcp-example/api/gigachat"
+)
+
+const (
+	DefaultOAuthToken = "test-oauth-token"
+	DefaultTokenTTL   = 30 * time.Minute
+	accessTokenPrefix = "test-access-token-"
+	oauthPath         = "/api/v2/oauth"
+	completionsPath   = "/api/v1/chat/completions"
+)
+
+// Response is a scripted answer of the completions endpoint
+type Response struct {
+	StatusCode   int // Non-200 status codes are returned with ErrorBody
+	ErrorBody    string
+	Content      string
+	FunctionCall *gigachat.FunctionCall
+	FinishReason string // Defaults to "stop", or "function_call" if FunctionCall is set
+}
+
+// Server implements the /api/v2/oauth and /api/v1/chat/completions endpoints.
+// Both endpoints are served from the same base URL.
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	oauthToken   string
+	tokenTTL     time.Duration
+	accessTokens map[string]time.Time
+	issued       int
+	oauthStatus  int
+	responses    []Response
+	requests     []gigachat.CompletionRequest
+}
+
+// NewServer starts a TLS server that accepts DefaultOAuthToken
+func NewServer() *Server {
+	s := &Server{
+		oauthToken:   DefaultOAuthToken,
+		tokenTTL:     DefaultTokenTTL,
+		accessTokens: map[string]time.Time{},
+		oauthStatus:  http.StatusOK,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+oauthPath, s.handleOAuth)
+	mux.HandleFunc("POST "+completionsPath, s.handleCompletions)
+	s.Server = httptest.NewTLSServer(mux)
+
+	return s
+}
+
+// Config returns a gigachat.Config pointing at this server
+func (s *Server) Config() gigachat.Config {
+	return gigachat.Config{
+		OAuthToken:   s.oauthToken,
+		OAuthBaseURL: s.URL,
+		APIBaseURL:   s.URL,
+		HTTPClient:   s.Client(),
+	}
+}
+
+// SetTokenTTL changes the lifetime of access tokens issued from now on
+func (s *Server) SetTokenTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenTTL = ttl
+}
+
+// SetOAuthStatus makes the oauth endpoint fail with the given status code
+func (s *Server) SetOAuthStatus(statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.oauthStatus = statusCode
+}
+
+// RevokeTokens invalidates every access token issued so far
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accessTokens = map[string]time.Time{}
+}
+
+// TokensIssued returns how many access tokens the oauth endpoint has handed out
+func (s *Server) TokensIssued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.issued
+}
+
+// Enqueue appends scripted responses for the completions endpoint
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, responses...)
+}
+
+// EnqueueText appends a final text answer
+func (s *Server) EnqueueText(content string) {
+	s.Enqueue(Response{Content: content})
+}
+
+// EnqueueFunctionCall appends an answer that asks to call a function
+func (s *Server) EnqueueFunctionCall(name string, arguments map[string]any) {
+	s.Enqueue(Response{FunctionCall: &gigachat.FunctionCall{Name: name, Arguments: arguments}})
+}
+
+// EnqueueError appends an error status with the given body
+func (s *Server) EnqueueError(statusCode int, body string) {
+	s.Enqueue(Response{StatusCode: statusCode, ErrorBody: body})
+}
+
+// Requests returns every authorized completions request received so far
+func (s *Server) Requests() []gigachat.CompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]gigachat.CompletionRequest(nil), s.requests...)
+}
+
+func (s *Server) handleOAuth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.oauthStatus != http.StatusOK {
+		writeError(w, s.oauthStatus, "oauth failure")
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.oauthToken {
+		writeError(w, http.StatusUnauthorized, "invalid oauth token")
+		return
+	}
+	if r.Header.Get("RqUID") == "" {
+		writeError(w, http.StatusBadRequest, "missing RqUID header")
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("scope") == "" {
+		writeError(w, http.StatusBadRequest, "missing scope")
+		return
+	}
+
+	s.issued++
+	accessToken := fmt.Sprintf("%s%d", accessTokenPrefix, s.issued)
+	expiresAt := time.Now().Add(s.tokenTTL)
+	s.accessTokens[accessToken] = expiresAt
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"expires_at":   expiresAt.UnixMilli(),
+	})
+}
+
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "token expired or invalid")
+		return
+	}
+
+	var completionReq gigachat.CompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&completionReq); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	s.requests = append(s.requests, completionReq)
+
+	if len(s.responses) == 0 {
+		writeError(w, http.StatusInternalServerError, "no scripted response left")
+		return
+	}
+	response := s.responses[0]
+	s.responses = s.responses[1:]
+
+	if response.StatusCode != 0 && response.StatusCode != http.StatusOK {
+		writeError(w, response.StatusCode, response.ErrorBody)
+		return
+	}
+
+	if completionReq.Stream {
+		writeStream(w, response)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, completionBody(completionReq.Model, response))
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	const bearerPrefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(bearerPrefix) {
+		return false
+	}
+	expiresAt, ok := s.accessTokens[header[len(bearerPrefix):]]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func finishReason(response Response) string {
+	if response.FinishReason != "" {
+		return response.FinishReason
+	}
+	if response.FunctionCall != nil {
+		return "function_call"
+	}
+	return "stop"
+}
+
+func completionBody(model string, response Response) map[string]any {
+	message := map[string]any{
+		"role":    "assistant",
+		"content": response.Content,
+	}
+	if response.FunctionCall != nil {
+		message["function_call"] = response.FunctionCall
+	}
+
+	return map[string]any{
+		"model":   model,
+		"created": time.Now().Unix(),
+		"object":  "chat.completion",
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       message,
+			"finish_reason": finishReason(response),
+		}},
+	}
+}
+
+// writeStream sends the response as server-sent events, one word per chunk,
+// followed by a chunk with the function call and finish reason
+func writeStream(w http.ResponseWriter, response Response) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	writeChunk := func(delta map[string]any, finishReason string) {
+		choice := map[string]any{"index": 0, "delta": delta}
+		if finishReason != "" {
+			choice["finish_reason"] = finishReason
+		}
+		data, _ := json.Marshal(map[string]any{"choices": []map[string]any{choice}})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	for i, word := range strings.SplitAfter(response.Content, " ") {
+		if word == "" {
+			continue
+		}
+		delta := map[string]any{"content": word}
+		if i == 0 {
+			delta["role"] = "assistant"
+		}
+		writeChunk(delta, "")
+	}
+
+	final := map[string]any{"content": ""}
+	if response.FunctionCall != nil {
+		final["role"] = "assistant"
+		final["function_call"] = response.FunctionCall
+	}
+	writeChunk(final, finishReason(response))
+
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]any{
+		"status":  statusCode,
+		"message": message,
+	})
+}
@@ -1,55 +1,136 @@
 package gigachat
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	oauthURL       = "https://ngw.devices.sberbank.ru:9443/api/v2/oauth"
-	completionsURL = "https://gigachat.devices.sberbank.ru/api/v1/chat/completions"
-	rqUID          = "270fee8f-3594-4cb7-b9cb-d0690691f735"
+	DefaultOAuthBaseURL = "https://ngw.devices.sberbank.ru:9443"
+	DefaultAPIBaseURL   = "https://gigachat.devices.sberbank.ru"
+	oauthPath           = "/api/v2/oauth"
+	completionsPath     = "/api/v1/chat/completions"
+	rqUID               = "270fee8f-3594-4cb7-b9cb-d0690691f735"
+	// Refresh the access token this long before it actually expires
+	tokenRefreshMargin = time.Minute
+	// Access token lifetime assumed when the oauth response has no expires_at
+	defaultTokenLifetime = 30 * time.Minute
 )
 
-type NetworkService struct{
-	oauthToken string
-	requestToken string
+// Config describes how to reach the GigaChat API
+type Config struct {
+	OAuthToken   string
+	OAuthBaseURL string       // Defaults to DefaultOAuthBaseURL
+	APIBaseURL   string       // Defaults to DefaultAPIBaseURL
+	HTTPClient   *http.Client // Defaults to a client that skips TLS verification
 }
 
-func GetNetworkService() (*NetworkService, error) {
+type NetworkService struct {
+	oauthToken     string
+	tokenMu        sync.Mutex // Guards requestToken and tokenExpiresAt
+	requestToken   string
+	tokenExpiresAt time.Time
+	oauthURL       string
+	completionsURL string
+	httpClient     *http.Client
+}
+
+// APIError is returned when the API responds with a non-200 status code
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("HTTP error: status code %d, body: %s", e.StatusCode, e.Body)
+}
+
+func GetNetworkService(ctx context.Context) (*NetworkService, error) {
+	return NewNetworkService(ctx, Config{OAuthToken: GetOauthToken()})
+}
+
+// NewNetworkService creates a service for the given config and fetches the first access token
+func NewNetworkService(ctx context.Context, config Config) (*NetworkService, error) {
+	if config.OAuthBaseURL == "" {
+		config.OAuthBaseURL = DefaultOAuthBaseURL
+	}
+	if config.APIBaseURL == "" {
+		config.APIBaseURL = DefaultAPIBaseURL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		}
+	}
+
 	networkService := &NetworkService{
-		oauthToken:		GetOauthToken(),
-		requestToken:	"",
+		oauthToken:     config.OAuthToken,
+		requestToken:   "",
+		oauthURL:       strings.TrimRight(config.OAuthBaseURL, "/") + oauthPath,
+		completionsURL: strings.TrimRight(config.APIBaseURL, "/") + completionsPath,
+		httpClient:     config.HTTPClient,
 	}
 
-	requestToken, err := networkService.GetRequestToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get request token: %w", err)
+	if _, err := networkService.accessToken(ctx, ""); err != nil {
+		return nil, err
 	}
-	networkService.requestToken = requestToken
 
 	return networkService, nil
 }
 
-func (networkService *NetworkService) GetRequestToken() (string, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+// accessToken returns a valid access token, fetching a new one if the current
+// token is about to expire or equals staleToken (the server rejected it).
+// Concurrent callers wait for a single refresh instead of each starting one.
+func (networkService *NetworkService) accessToken(ctx context.Context, staleToken string) (string, error) {
+	networkService.tokenMu.Lock()
+	defer networkService.tokenMu.Unlock()
+
+	expiring := time.Now().Add(tokenRefreshMargin).After(networkService.tokenExpiresAt)
+	stale := staleToken != "" && staleToken == networkService.requestToken
+	if networkService.requestToken != "" && !expiring && !stale {
+		return networkService.requestToken, nil
+	}
+
+	tokenResp, err := networkService.fetchToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get request token: %w", err)
+	}
+
+	networkService.requestToken = tokenResp.AccessToken
+	networkService.tokenExpiresAt = time.Now().Add(defaultTokenLifetime)
+	if tokenResp.ExpiresAt != 0 {
+		networkService.tokenExpiresAt = time.UnixMilli(tokenResp.ExpiresAt)
 	}
 
+	return networkService.requestToken, nil
+}
+
+// GetRequestToken returns the access token the service currently uses for
+// API requests, refreshing it first if it is about to expire
+func (networkService *NetworkService) GetRequestToken(ctx context.Context) (string, error) {
+	return networkService.accessToken(ctx, "")
+}
+
+func (networkService *NetworkService) fetchToken(ctx context.Context) (*TokenResponse, error) {
 	data := url.Values{}
 	data.Set("scope", "GIGACHAT_API_PERS")
 
-	req, err := http.NewRequest("POST", oauthURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", networkService.oauthURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded") //what does it mean?
@@ -57,34 +138,141 @@ func (networkService *NetworkService) GetRequestToken() (string, error) {
 	req.Header.Set("Authorization", "Bearer "+networkService.oauthToken)
 	req.Header.Set("RqUID", rqUID)
 
-	resp, err := client.Do(req)
+	resp, err := networkService.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var tokenResp TokenResponse
 	err = json.Unmarshal(body, &tokenResp)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return tokenResp.AccessToken, nil
+	return &tokenResp, nil
 }
 
-func (networkService *NetworkService) GetCompletion(messages []Message, model string, temperature float64, functions []Function) (*CompletionResult, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+func (networkService *NetworkService) GetCompletion(ctx context.Context, messages []Message, model string, temperature float64, functions []Function, functionCall string) (*CompletionResult, error) {
+	reqData := newCompletionRequest(messages, model, temperature, functions, functionCall)
+
+	resp, err := networkService.postCompletion(ctx, reqData, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var completionResp CompletionResponse
+	err = json.Unmarshal(body, &completionResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(completionResp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from API")
+	}
+
+	choice := completionResp.Choices[0]
+
+	return &CompletionResult{
+		Message: &Message{
+			Role:         choice.Message.Role,
+			Content:      choice.Message.Content,
+			FunctionCall: choice.Message.FunctionCall,
 		},
+		FinishReason: choice.FinishReason,
+		FunctionCall: choice.Message.FunctionCall,
+	}, nil
+}
+
+// GetCompletionStream requests a completion in server-sent events mode.
+// onDelta is called with every piece of generated text as it arrives;
+// the assembled result is returned once the stream is finished.
+func (networkService *NetworkService) GetCompletionStream(ctx context.Context, messages []Message, model string, temperature float64, functions []Function, functionCall string, onDelta func(delta string)) (*CompletionResult, error) {
+	reqData := newCompletionRequest(messages, model, temperature, functions, functionCall)
+	reqData.Stream = true
+
+	resp, err := networkService.postCompletion(ctx, reqData, "text/event-stream")
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
+
+	result := &CompletionResult{
+		Message: &Message{Role: "assistant"},
+	}
+	var content strings.Builder
+	received := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Only "data:" lines carry payload; blank lines separate events
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk CompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		for _, choice := range chunk.Choices {
+			received = true
+			if choice.Delta.Role != "" {
+				result.Message.Role = choice.Delta.Role
+			}
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				if onDelta != nil {
+					onDelta(choice.Delta.Content)
+				}
+			}
+			if choice.Delta.FunctionCall != nil {
+				result.FunctionCall = choice.Delta.FunctionCall
+			}
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if !received {
+		return nil, fmt.Errorf("no response from API")
+	}
+
+	result.Message.Content = content.String()
+	result.Message.FunctionCall = result.FunctionCall
+
+	return result, nil
+}
 
-	reqData := CompletionRequest{
+// newCompletionRequest builds the request body. functionCall is "auto" or
+// "none"; an empty value means "auto".
+func newCompletionRequest(messages []Message, model string, temperature float64, functions []Function, functionCall string) *CompletionRequest {
+	reqData := &CompletionRequest{
 		Model:             model,
 		Messages:          messages,
 		Temperature:       temperature,
@@ -93,54 +281,71 @@ func (networkService *NetworkService) GetCompletion(messages []Message, model st
 
 	// Add functions if provided
 	if len(functions) > 0 {
-		reqData.FunctionCall = "auto"
+		reqData.FunctionCall = functionCall
+		if reqData.FunctionCall == "" {
+			reqData.FunctionCall = "auto"
+		}
 		reqData.Functions = functions
 	}
 
+	return reqData
+}
+
+// postCompletion sends the request with a valid access token and returns the
+// successful response with an unread body. On a 401 the token is refreshed
+// and the request is retried once.
+func (networkService *NetworkService) postCompletion(ctx context.Context, reqData *CompletionRequest, accept string) (*http.Response, error) {
 	jsonData, err := json.Marshal(reqData)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", completionsURL, bytes.NewBuffer(jsonData))
+	requestToken, err := networkService.accessToken(ctx, "")
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+networkService.requestToken)
+	resp, err := networkService.doCompletionRequest(ctx, jsonData, accept, requestToken)
 
-	resp, err := client.Do(req)
+	// The token may have been revoked before its expiry - refresh it and retry once
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		requestToken, err = networkService.accessToken(ctx, requestToken)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = networkService.doCompletionRequest(ctx, jsonData, accept, requestToken)
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	return resp, nil
+}
+
+func (networkService *NetworkService) doCompletionRequest(ctx context.Context, jsonData []byte, accept string, requestToken string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", networkService.completionsURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
-	var completionResp CompletionResponse
-	err = json.Unmarshal(body, &completionResp)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	resp, err := networkService.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, err
 	}
 
-	if len(completionResp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from API")
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
-	choice := completionResp.Choices[0]
-
-	return &CompletionResult{
-		Message: &Message{
-			Role:         choice.Message.Role,
-			Content:      choice.Message.Content,
-			FunctionCall: choice.Message.FunctionCall,
-		},
-		FinishReason: choice.FinishReason,
-		FunctionCall: choice.Message.FunctionCall,
-	}, nil
+	return resp, nil
 }
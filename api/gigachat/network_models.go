@@ -2,6 +2,7 @@ package gigachat
 
 type TokenResponse struct {
 	AccessToken string `json:"access_token"`
+	ExpiresAt   int64  `json:"expires_at"` // Unix time in milliseconds
 }
 
 type Message struct {
@@ -28,6 +29,7 @@ type CompletionRequest struct {
 	Functions         []Function `json:"functions,omitempty"`
 	Temperature       float64    `json:"temperature"`
 	RepetitionPenalty float64    `json:"repetition_penalty"`
+	Stream            bool       `json:"stream,omitempty"`
 }
 
 type CompletionResponse struct {
@@ -41,6 +43,18 @@ type CompletionResponse struct {
 	} `json:"choices"`
 }
 
+// CompletionChunk is a single server-sent event of a streamed completion
+type CompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Role         string        `json:"role"`
+			Content      string        `json:"content"`
+			FunctionCall *FunctionCall `json:"function_call,omitempty"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
 type CompletionResult struct {
 	Message       *Message
 	FinishReason  string
@@ -0,0 +1,224 @@
+package gigachat_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"serge.com/mcp-example/api/gigachat"
+	"serge.com/mcp-example/api/gigachat/gigachattest"
+)
+
+var userMessages = []gigachat.Message{{Role: "user", Content: "Hi"}}
+
+func newService(t *testing.T, server *gigachattest.Server) *gigachat.NetworkService {
+	t.Helper()
+
+	networkService, err := gigachat.NewNetworkService(context.Background(), server.Config())
+	if err != nil {
+		t.Fatalf("NewNetworkService() error = %v", err)
+	}
+	return networkService
+}
+
+func wantAPIError(t *testing.T, err error, statusCode int) {
+	t.Helper()
+
+	var apiErr *gigachat.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != statusCode {
+		t.Fatalf("error = %v, want APIError with status %d", err, statusCode)
+	}
+}
+
+func TestNewNetworkService(t *testing.T) {
+	server := gigachattest.NewServer()
+	defer server.Close()
+
+	newService(t, server)
+	if issued := server.TokensIssued(); issued != 1 {
+		t.Errorf("tokens issued = %d, want 1", issued)
+	}
+
+	config := server.Config()
+	config.OAuthToken = "wrong"
+	_, err := gigachat.NewNetworkService(context.Background(), config)
+	wantAPIError(t, err, http.StatusUnauthorized)
+
+	server.SetOAuthStatus(http.StatusServiceUnavailable)
+	_, err = gigachat.NewNetworkService(context.Background(), server.Config())
+	wantAPIError(t, err, http.StatusServiceUnavailable)
+}
+
+func TestGetCompletion(t *testing.T) {
+	server := gigachattest.NewServer()
+	defer server.Close()
+	networkService := newService(t, server)
+
+	t.Run("text", func(t *testing.T) {
+		server.EnqueueText("Hello!")
+
+		result, err := networkService.GetCompletion(context.Background(), userMessages, "GigaChat-2", 0, nil, "")
+		if err != nil {
+			t.Fatalf("GetCompletion() error = %v", err)
+		}
+		if result.Message.Content != "Hello!" || result.FinishReason != "stop" || result.FunctionCall != nil {
+			t.Errorf("GetCompletion() = %+v, %+v", result, result.Message)
+		}
+	})
+
+	t.Run("function call", func(t *testing.T) {
+		server.EnqueueFunctionCall("get_crypto_price", map[string]any{"coin_id": "bitcoin"})
+		functions := []gigachat.Function{{Name: "get_crypto_price", Description: "Price", Parameters: map[string]any{"type": "object"}}}
+
+		result, err := networkService.GetCompletion(context.Background(), userMessages, "GigaChat-2", 0, functions, "")
+		if err != nil {
+			t.Fatalf("GetCompletion() error = %v", err)
+		}
+		if result.FinishReason != "function_call" || result.FunctionCall == nil ||
+			result.FunctionCall.Name != "get_crypto_price" || result.FunctionCall.Arguments["coin_id"] != "bitcoin" {
+			t.Errorf("GetCompletion() = %+v, function call %+v", result, result.FunctionCall)
+		}
+
+		requests := server.Requests()
+		last := requests[len(requests)-1]
+		if last.FunctionCall != "auto" || len(last.Functions) != 1 || last.Functions[0].Name != "get_crypto_price" {
+			t.Errorf("request function_call = %q, functions = %+v", last.FunctionCall, last.Functions)
+		}
+	})
+
+	for _, statusCode := range []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError} {
+		t.Run(http.StatusText(statusCode), func(t *testing.T) {
+			server.EnqueueError(statusCode, "failure")
+
+			_, err := networkService.GetCompletion(context.Background(), userMessages, "GigaChat-2", 0, nil, "")
+			wantAPIError(t, err, statusCode)
+		})
+	}
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	t.Run("reused while valid", func(t *testing.T) {
+		server := gigachattest.NewServer()
+		defer server.Close()
+		networkService := newService(t, server)
+
+		server.EnqueueText("one")
+		server.EnqueueText("two")
+		for range 2 {
+			if _, err := networkService.GetCompletion(context.Background(), userMessages, "", 0, nil, ""); err != nil {
+				t.Fatalf("GetCompletion() error = %v", err)
+			}
+		}
+
+		if issued := server.TokensIssued(); issued != 1 {
+			t.Errorf("tokens issued = %d, want 1", issued)
+		}
+		token, err := networkService.GetRequestToken(context.Background())
+		if err != nil || !strings.HasSuffix(token, "-1") {
+			t.Errorf("GetRequestToken() = %q, %v; want the first token", token, err)
+		}
+	})
+
+	t.Run("refreshed before expiry", func(t *testing.T) {
+		server := gigachattest.NewServer()
+		defer server.Close()
+		// Tokens that expire within the refresh margin are replaced before use
+		server.SetTokenTTL(30 * time.Second)
+		networkService := newService(t, server)
+
+		server.EnqueueText("one")
+		if _, err := networkService.GetCompletion(context.Background(), userMessages, "", 0, nil, ""); err != nil {
+			t.Fatalf("GetCompletion() error = %v", err)
+		}
+
+		if issued := server.TokensIssued(); issued != 2 {
+			t.Errorf("tokens issued = %d, want 2", issued)
+		}
+	})
+
+	t.Run("retried after 401", func(t *testing.T) {
+		server := gigachattest.NewServer()
+		defer server.Close()
+		networkService := newService(t, server)
+
+		server.RevokeTokens()
+		server.EnqueueText("after refresh")
+		result, err := networkService.GetCompletion(context.Background(), userMessages, "", 0, nil, "")
+		if err != nil {
+			t.Fatalf("GetCompletion() error = %v", err)
+		}
+		if result.Message.Content != "after refresh" {
+			t.Errorf("GetCompletion() content = %q", result.Message.Content)
+		}
+		if issued := server.TokensIssued(); issued != 2 {
+			t.Errorf("tokens issued = %d, want 2", issued)
+		}
+	})
+
+	t.Run("401 after refresh is returned", func(t *testing.T) {
+		server := gigachattest.NewServer()
+		defer server.Close()
+		networkService := newService(t, server)
+
+		server.EnqueueError(http.StatusUnauthorized, "denied")
+		server.EnqueueError(http.StatusUnauthorized, "denied again")
+		server.EnqueueText("not reached")
+		_, err := networkService.GetCompletion(context.Background(), userMessages, "", 0, nil, "")
+		wantAPIError(t, err, http.StatusUnauthorized)
+
+		if issued := server.TokensIssued(); issued != 2 {
+			t.Errorf("tokens issued = %d, want 2", issued)
+		}
+	})
+}
+
+func TestGetCompletionStream(t *testing.T) {
+	server := gigachattest.NewServer()
+	defer server.Close()
+	networkService := newService(t, server)
+
+	t.Run("text", func(t *testing.T) {
+		server.EnqueueText("Bitcoin costs a lot")
+
+		var deltas []string
+		result, err := networkService.GetCompletionStream(context.Background(), userMessages, "", 0, nil, "", func(delta string) {
+			deltas = append(deltas, delta)
+		})
+		if err != nil {
+			t.Fatalf("GetCompletionStream() error = %v", err)
+		}
+
+		if want := []string{"Bitcoin ", "costs ", "a ", "lot"}; strings.Join(deltas, "|") != strings.Join(want, "|") {
+			t.Errorf("deltas = %q, want %q", deltas, want)
+		}
+		if result.Message.Content != "Bitcoin costs a lot" || result.Message.Role != "assistant" || result.FinishReason != "stop" {
+			t.Errorf("GetCompletionStream() = %+v, %+v", result, result.Message)
+		}
+		if requests := server.Requests(); !requests[len(requests)-1].Stream {
+			t.Errorf("request was not sent in stream mode")
+		}
+	})
+
+	t.Run("function call", func(t *testing.T) {
+		server.EnqueueFunctionCall("greet", map[string]any{"name": "Ann"})
+
+		result, err := networkService.GetCompletionStream(context.Background(), userMessages, "", 0, nil, "", nil)
+		if err != nil {
+			t.Fatalf("GetCompletionStream() error = %v", err)
+		}
+		if result.FinishReason != "function_call" || result.FunctionCall == nil || result.FunctionCall.Name != "greet" ||
+			result.Message.FunctionCall != result.FunctionCall {
+			t.Errorf("GetCompletionStream() = %+v, function call %+v", result, result.FunctionCall)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		server.EnqueueError(http.StatusInternalServerError, "failure")
+
+		_, err := networkService.GetCompletionStream(context.Background(), userMessages, "", 0, nil, "", nil)
+		wantAPIError(t, err, http.StatusInternalServerError)
+	})
+}
@@ -0,0 +1,132 @@
+package gigachat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"serge.com/mcp-example/llm"
+)
+
+const DefaultModel = "GigaChat-2"
+
+// Provider adapts NetworkService to the llm.Provider interface
+type Provider struct {
+	networkService *NetworkService
+}
+
+// NewProvider creates an llm.Provider backed by the GigaChat API
+func NewProvider(networkService *NetworkService) *Provider {
+	return &Provider{networkService: networkService}
+}
+
+// Complete converts the request to GigaChat types and performs a completion
+func (p *Provider) Complete(ctx context.Context, req *llm.Request) (*llm.Completion, error) {
+	model, messages, err := toCompletionParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.networkService.GetCompletion(ctx, messages, model, req.Temperature, toFunctions(req.Tools), string(req.ToolChoice))
+	if err != nil {
+		return nil, err
+	}
+
+	return fromCompletionResult(result), nil
+}
+
+// CompleteStream is like Complete but streams generated text to onDelta
+func (p *Provider) CompleteStream(ctx context.Context, req *llm.Request, onDelta func(delta string)) (*llm.Completion, error) {
+	model, messages, err := toCompletionParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := p.networkService.GetCompletionStream(ctx, messages, model, req.Temperature, toFunctions(req.Tools), string(req.ToolChoice), onDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromCompletionResult(result), nil
+}
+
+func toCompletionParams(req *llm.Request) (string, []Message, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultModel
+	}
+
+	messages, err := toMessages(req.Messages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return model, messages, nil
+}
+
+func toMessages(messages []llm.Message) ([]Message, error) {
+	result := make([]Message, 0, len(messages))
+
+	for _, message := range messages {
+		switch message.Role {
+		case llm.RoleTool:
+			// GigaChat expects: {"name": "function_name", "arguments": {"result": "..."}}
+			// JSON-encode the tool result to ensure proper escaping
+			resultJSON, err := json.Marshal(message.Content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool result: %w", err)
+			}
+			result = append(result, Message{
+				Role:    "function",
+				Content: fmt.Sprintf(`{"name": "%s", "arguments": %s}`, message.Name, string(resultJSON)),
+			})
+		default:
+			gigaChatMessage := Message{
+				Role:    message.Role,
+				Content: message.Content,
+			}
+			// GigaChat supports a single function call per assistant message
+			if len(message.ToolCalls) > 0 {
+				gigaChatMessage.FunctionCall = &FunctionCall{
+					Name:      message.ToolCalls[0].Name,
+					Arguments: message.ToolCalls[0].Arguments,
+				}
+			}
+			result = append(result, gigaChatMessage)
+		}
+	}
+
+	return result, nil
+}
+
+func toFunctions(tools []llm.Tool) []Function {
+	functions := make([]Function, 0, len(tools))
+	for _, tool := range tools {
+		functions = append(functions, Function{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
+	return functions
+}
+
+func fromCompletionResult(result *CompletionResult) *llm.Completion {
+	completion := &llm.Completion{
+		Message: llm.Message{
+			Role:    llm.RoleAssistant,
+			Content: result.Message.Content,
+		},
+		FinishReason: llm.FinishReason(result.FinishReason),
+	}
+
+	if result.FinishReason == "function_call" && result.FunctionCall != nil {
+		completion.FinishReason = llm.FinishReasonToolCalls
+		completion.Message.ToolCalls = []llm.ToolCall{{
+			Name:      result.FunctionCall.Name,
+			Arguments: result.FunctionCall.Arguments,
+		}}
+	}
+
+	return completion
+}
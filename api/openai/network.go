@@ -0,0 +1,73 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"serge.com/mcp-example/common/network"
+)
+
+const (
+	chatCompletionsPath = "/chat/completions"
+	defaultTimeout      = 120 * time.Second
+)
+
+// NetworkService is a client for OpenAI-compatible chat completions APIs
+type NetworkService struct {
+	networkClient *network.Client
+	baseURL       string
+	apiKey        string
+}
+
+// NewNetworkService creates a client for the API at baseURL (e.g. http://localhost:8080/v1).
+// apiKey may be empty for local inference servers that don't require authentication.
+func NewNetworkService(baseURL string, apiKey string) *NetworkService {
+	return &NetworkService{
+		networkClient: network.NewClient(defaultTimeout),
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		apiKey:        apiKey,
+	}
+}
+
+// GetChatCompletion performs a blocking /chat/completions request
+func (networkService *NetworkService) GetChatCompletion(ctx context.Context, reqData *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	jsonData, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		"Accept":       "application/json",
+	}
+	if networkService.apiKey != "" {
+		headers["Authorization"] = "Bearer " + networkService.apiKey
+	}
+
+	resp, err := networkService.networkClient.Do(&network.Request{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     networkService.baseURL + chatCompletionsPath,
+		Headers: headers,
+		Body:    bytes.NewReader(jsonData),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get completion: %w", err)
+	}
+
+	var completionResp ChatCompletionResponse
+	if err := json.Unmarshal(resp.Body, &completionResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(completionResp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from API")
+	}
+
+	return &completionResp, nil
+}
@@ -0,0 +1,148 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"serge.com/mcp-example/llm"
+)
+
+// Provider adapts NetworkService to the llm.Provider interface
+type Provider struct {
+	networkService *NetworkService
+	defaultModel   string
+}
+
+// NewProvider creates an llm.Provider backed by an OpenAI-compatible API.
+// defaultModel is used when the request doesn't specify a model.
+func NewProvider(networkService *NetworkService, defaultModel string) *Provider {
+	return &Provider{
+		networkService: networkService,
+		defaultModel:   defaultModel,
+	}
+}
+
+// Complete converts the request to OpenAI types and performs a completion
+func (p *Provider) Complete(ctx context.Context, req *llm.Request) (*llm.Completion, error) {
+	model := req.Model
+	if model == "" {
+		model = p.defaultModel
+	}
+
+	messages, err := toChatMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	reqData := &ChatCompletionRequest{
+		Model:       model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+	}
+
+	// Add tools if provided
+	if len(req.Tools) > 0 {
+		reqData.ToolChoice = string(llm.ToolChoiceAuto)
+		if req.ToolChoice != "" {
+			reqData.ToolChoice = string(req.ToolChoice)
+		}
+		reqData.Tools = toToolDefinitions(req.Tools)
+	}
+
+	resp, err := p.networkService.GetChatCompletion(ctx, reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromChoice(resp.Choices[0].Message, resp.Choices[0].FinishReason)
+}
+
+func toChatMessages(messages []llm.Message) ([]ChatMessage, error) {
+	result := make([]ChatMessage, 0, len(messages))
+
+	for _, message := range messages {
+		content := message.Content
+		chatMessage := ChatMessage{
+			Role:    message.Role,
+			Content: &content,
+		}
+
+		switch message.Role {
+		case llm.RoleTool:
+			chatMessage.ToolCallID = message.ToolCallID
+			chatMessage.Name = message.Name
+		case llm.RoleAssistant:
+			if len(message.ToolCalls) > 0 && content == "" {
+				chatMessage.Content = nil
+			}
+			for _, toolCall := range message.ToolCalls {
+				argumentsJSON, err := json.Marshal(toolCall.Arguments)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal arguments for tool %s: %w", toolCall.Name, err)
+				}
+				chatMessage.ToolCalls = append(chatMessage.ToolCalls, ToolCall{
+					ID:   toolCall.ID,
+					Type: "function",
+					Function: FunctionCall{
+						Name:      toolCall.Name,
+						Arguments: string(argumentsJSON),
+					},
+				})
+			}
+		}
+
+		result = append(result, chatMessage)
+	}
+
+	return result, nil
+}
+
+func toToolDefinitions(tools []llm.Tool) []ToolDefinition {
+	definitions := make([]ToolDefinition, 0, len(tools))
+	for _, tool := range tools {
+		definitions = append(definitions, ToolDefinition{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+func fromChoice(message ChatMessage, finishReason string) (*llm.Completion, error) {
+	completion := &llm.Completion{
+		Message: llm.Message{
+			Role: llm.RoleAssistant,
+		},
+		FinishReason: llm.FinishReason(finishReason),
+	}
+	if message.Content != nil {
+		completion.Message.Content = *message.Content
+	}
+
+	for _, toolCall := range message.ToolCalls {
+		// Arguments arrive as a JSON-encoded string; an empty string means no arguments
+		arguments := map[string]any{}
+		if toolCall.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
+				return nil, fmt.Errorf("failed to parse arguments for tool %s: %w", toolCall.Function.Name, err)
+			}
+		}
+		completion.Message.ToolCalls = append(completion.Message.ToolCalls, llm.ToolCall{
+			ID:        toolCall.ID,
+			Name:      toolCall.Function.Name,
+			Arguments: arguments,
+		})
+	}
+
+	// Some servers report "stop" even when they return tool calls
+	if len(completion.Message.ToolCalls) > 0 {
+		completion.FinishReason = llm.FinishReasonToolCalls
+	}
+
+	return completion, nil
+}
@@ -0,0 +1,148 @@
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"serge.com/mcp-example/api/openai"
+	"serge.com/mcp-example/llm"
+)
+
+// newServer starts a chat completions server that records the request body
+// and answers with response
+func newServer(t *testing.T, response string) (*httptest.Server, *map[string]any) {
+	t.Helper()
+
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(body, &request)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, &request
+}
+
+func newProvider(server *httptest.Server) *openai.Provider {
+	return openai.NewProvider(openai.NewNetworkService(server.URL+"/v1/", "key"), "local-model")
+}
+
+const textResponse = `{"id":"1","model":"local-model","choices":[{"index":0,"message":{"role":"assistant","content":"Done"},"finish_reason":"stop"}]}`
+
+var tools = []llm.Tool{{Name: "fs__read_files", Description: "Read files", Parameters: map[string]any{"type": "object"}}}
+
+func TestCompleteRequestMessages(t *testing.T) {
+	server, request := newServer(t, textResponse)
+
+	messages := []llm.Message{
+		{Role: llm.RoleUser, Content: "Read a.txt"},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "fs__read_files", Arguments: map[string]any{"path": "a.txt"}}}},
+		{Role: llm.RoleTool, Content: "text", ToolCallID: "call_1", Name: "fs__read_files"},
+	}
+	completion, err := newProvider(server).Complete(context.Background(), &llm.Request{Messages: messages, Tools: tools})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if completion.Message.Content != "Done" || completion.FinishReason != llm.FinishReasonStop {
+		t.Errorf("Complete() = %+v", completion)
+	}
+
+	if model := (*request)["model"]; model != "local-model" {
+		t.Errorf("model = %v, want the default model", model)
+	}
+	if toolChoice := (*request)["tool_choice"]; toolChoice != "auto" {
+		t.Errorf("tool_choice = %v, want auto", toolChoice)
+	}
+
+	sent, _ := (*request)["messages"].([]any)
+	if len(sent) != 3 {
+		t.Fatalf("messages = %v, want 3", sent)
+	}
+
+	// An assistant message with only tool calls has null content, not ""
+	assistant := sent[1].(map[string]any)
+	if content, ok := assistant["content"]; !ok || content != nil {
+		t.Errorf("assistant content = %#v, want null", content)
+	}
+	toolCalls, _ := assistant["tool_calls"].([]any)
+	if len(toolCalls) != 1 {
+		t.Fatalf("assistant tool_calls = %v, want 1", assistant["tool_calls"])
+	}
+	function := toolCalls[0].(map[string]any)["function"].(map[string]any)
+	if function["name"] != "fs__read_files" || function["arguments"] != `{"path":"a.txt"}` {
+		t.Errorf("tool call function = %v", function)
+	}
+
+	toolResult := sent[2].(map[string]any)
+	if toolResult["tool_call_id"] != "call_1" || toolResult["name"] != "fs__read_files" || toolResult["content"] != "text" {
+		t.Errorf("tool result = %v, want tool_call_id, name and content", toolResult)
+	}
+}
+
+func TestCompleteToolChoice(t *testing.T) {
+	tests := []struct {
+		name       string
+		tools      []llm.Tool
+		toolChoice llm.ToolChoice
+		want       any
+	}{
+		{name: "default", tools: tools, want: "auto"},
+		{name: "none", tools: tools, toolChoice: llm.ToolChoiceNone, want: "none"},
+		{name: "no tools", toolChoice: llm.ToolChoiceNone, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, request := newServer(t, textResponse)
+
+			req := &llm.Request{Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}, Tools: tt.tools, ToolChoice: tt.toolChoice}
+			if _, err := newProvider(server).Complete(context.Background(), req); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if toolChoice := (*request)["tool_choice"]; toolChoice != tt.want {
+				t.Errorf("tool_choice = %v, want %v", toolChoice, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteToolCalls(t *testing.T) {
+	// Some servers finish with "stop" although they return tool calls
+	server, _ := newServer(t, `{"id":"2","model":"local-model","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[`+
+		`{"id":"call_1","type":"function","function":{"name":"fs__read_files","arguments":"{\"path\":\"a.txt\"}"}},`+
+		`{"id":"call_2","type":"function","function":{"name":"fs__list_files","arguments":""}}]},"finish_reason":"stop"}]}`)
+
+	completion, err := newProvider(server).Complete(context.Background(), &llm.Request{Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}, Tools: tools})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if completion.FinishReason != llm.FinishReasonToolCalls || completion.Message.Content != "" {
+		t.Errorf("Complete() = %+v, want tool_calls without content", completion)
+	}
+
+	toolCalls := completion.Message.ToolCalls
+	if len(toolCalls) != 2 {
+		t.Fatalf("tool calls = %+v, want 2", toolCalls)
+	}
+	if toolCalls[0].ID != "call_1" || toolCalls[0].Name != "fs__read_files" || toolCalls[0].Arguments["path"] != "a.txt" {
+		t.Errorf("first tool call = %+v", toolCalls[0])
+	}
+	if toolCalls[1].ID != "call_2" || len(toolCalls[1].Arguments) != 0 {
+		t.Errorf("second tool call = %+v, want no arguments", toolCalls[1])
+	}
+}
@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 
 	"serge.com/mcp-example/agent"
 	"serge.com/mcp-example/api/gigachat"
+	"serge.com/mcp-example/api/openai"
+	"serge.com/mcp-example/llm"
 	"serge.com/mcp-example/mcp_client"
 )
 
+var configPath = flag.String("config", "mcp_servers.json", "path to the MCP servers config file")
+
 func main() {
-	mcpClient := getMCPClient()
-	gigaChatNetworkService := getNetworkService()
-	agentInstance, err := agent.NewAgent(gigaChatNetworkService, mcpClient)
+	ctx := context.Background()
+	flag.Parse()
+
+	mcpClients := getMCPClients(ctx)
+	provider := getProvider(ctx)
+	agentInstance, err := agent.NewAgent(ctx, provider, mcpClients...)
 	if err != nil {
 		fmt.Println("Agent creation error: ", err)
 		os.Exit(1)
@@ -24,25 +33,38 @@ func main() {
 
 	agentInstance.SetContext(testContext)
 
-	testMessage(agentInstance, "In which year did WW2 start?")
-	testMessage(agentInstance, "How much is bitcoin price in usd?")
-	testMessage(agentInstance, 
+	testMessage(ctx, agentInstance, "In which year did WW2 start?")
+	testMessage(ctx, agentInstance, "How much is bitcoin price in usd?")
+	testMessage(ctx, agentInstance, 
 		"Найди все текстовые файлы, прочитай их и коротко перескажи что там. Результат сохрани в файл summary.txt")
 	
 }
 
-func testMessage(agent *agent.Agent, message string) {
+func testMessage(ctx context.Context, agent *agent.Agent, message string) {
 	fmt.Printf("User Message:\n> %s\n\n", message)
-	answer, err := agent.SendMessage(message)
+	fmt.Print("Agent Answer:\n> ")
+	_, err := agent.SendMessageStream(ctx, message, func(delta string) {
+		fmt.Print(delta)
+	})
 	if err != nil {
 		fmt.Println("Error getting answer from GigaChat: ", err)
 		os.Exit(1)
 	}
-	fmt.Printf("Agent Answer:\n> %s\n\n", answer)
+	fmt.Print("\n\n")
+}
+
+// getProvider uses an OpenAI-compatible server when OPENAI_BASE_URL is set
+// (e.g. a locally hosted model) and falls back to GigaChat otherwise
+func getProvider(ctx context.Context) llm.Provider {
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		networkService := openai.NewNetworkService(baseURL, os.Getenv("OPENAI_API_KEY"))
+		return openai.NewProvider(networkService, os.Getenv("OPENAI_MODEL"))
+	}
+	return gigachat.NewProvider(getNetworkService(ctx))
 }
 
-func getNetworkService() *gigachat.NetworkService {
-	networkService, err := gigachat.GetNetworkService()
+func getNetworkService(ctx context.Context) *gigachat.NetworkService {
+	networkService, err := gigachat.GetNetworkService(ctx)
 	if err != nil {
 		fmt.Println("Network service creation error: ", err)
 		os.Exit(1)
@@ -50,11 +72,18 @@ func getNetworkService() *gigachat.NetworkService {
 	return networkService
 }
 
-func getMCPClient() *mcpclient.MCPClient {
-	mcpClient, err := mcpclient.NewMCPClient("/Users/sergeyusachev/Projects/GoProjects/MCP_Example/mcp_server/myserver")
+func getMCPClients(ctx context.Context) []*mcpclient.MCPClient {
+	config, err := mcpclient.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("MCP config error: ", err)
+		os.Exit(1)
+	}
+	// Destructive file tools ask for confirmation on the terminal
+	opts := &mcpclient.Options{ElicitationHandler: mcpclient.ConfirmOnTerminal(os.Stdin, os.Stdout)}
+	mcpClients, err := mcpclient.ConnectAll(ctx, config, opts)
 	if err != nil {
 		fmt.Println("MCP client creation error: ", err)
 		os.Exit(1)
 	}
-	return mcpClient
-}
\ No newline at end of file
+	return mcpClients
+}
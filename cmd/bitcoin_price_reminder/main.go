@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -9,17 +11,28 @@ import (
 
 	"serge.com/mcp-example/agent"
 	"serge.com/mcp-example/api/gigachat"
+	"serge.com/mcp-example/api/openai"
+	"serge.com/mcp-example/llm"
 	"serge.com/mcp-example/mcp_client"
 )
 
+var configPath = flag.String("config", "mcp_servers.json", "path to the MCP servers config file")
+
 func main() {
-	mcpClient := getMCPClient()
-	gigaChatNetworkService := getNetworkService()
-	agentInstance, err := agent.NewAgent(gigaChatNetworkService, mcpClient)
+	// Cancelled on interrupt, which also aborts a check that is in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	flag.Parse()
+
+	mcpClients := getMCPClients(ctx)
+	provider := getProvider(ctx)
+	agentInstance, err := agent.NewAgent(ctx, provider, mcpClients...)
 	if err != nil {
 		fmt.Println("Agent creation error: ", err)
 		os.Exit(1)
 	}
+	agentInstance.TurnTimeout = 30 * time.Second
 	testContext := ""
 	agentInstance.SetContext(testContext)
 
@@ -32,20 +45,16 @@ func main() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
-	// Create a channel to listen for interrupt signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
 	// Run the first check immediately
-	checkBitcoinPrice(agentInstance)
+	checkBitcoinPrice(ctx, agentInstance)
 
 	// Main loop - runs forever until interrupted
 	for {
 		select {
 		case <-ticker.C:
 			// Ticker triggered - check Bitcoin price
-			checkBitcoinPrice(agentInstance)
-		case <-sigChan:
+			checkBitcoinPrice(ctx, agentInstance)
+		case <-ctx.Done():
 			// Received interrupt signal - exit gracefully
 			fmt.Println("\n\nShutting down Bitcoin Price Reminder Service...")
 			return
@@ -53,12 +62,12 @@ func main() {
 	}
 }
 
-func checkBitcoinPrice(agentInstance *agent.Agent) {
+func checkBitcoinPrice(ctx context.Context, agentInstance *agent.Agent) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	fmt.Printf("[%s] Checking Bitcoin price...\n", timestamp)
 
 	question := "What is the current Bitcoin price in USD?"
-	answer, err := agentInstance.SendMessage(question)
+	answer, err := agentInstance.SendMessage(ctx, question)
 	if err != nil {
 		fmt.Printf("[ERROR] Failed to get Bitcoin price: %v\n\n", err)
 		return
@@ -69,8 +78,18 @@ func checkBitcoinPrice(agentInstance *agent.Agent) {
 	fmt.Println()
 }
 
-func getNetworkService() *gigachat.NetworkService {
-	networkService, err := gigachat.GetNetworkService()
+// getProvider uses an OpenAI-compatible server when OPENAI_BASE_URL is set
+// (e.g. a locally hosted model) and falls back to GigaChat otherwise
+func getProvider(ctx context.Context) llm.Provider {
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		networkService := openai.NewNetworkService(baseURL, os.Getenv("OPENAI_API_KEY"))
+		return openai.NewProvider(networkService, os.Getenv("OPENAI_MODEL"))
+	}
+	return gigachat.NewProvider(getNetworkService(ctx))
+}
+
+func getNetworkService(ctx context.Context) *gigachat.NetworkService {
+	networkService, err := gigachat.GetNetworkService(ctx)
 	if err != nil {
 		fmt.Println("Network service creation error: ", err)
 		os.Exit(1)
@@ -78,11 +97,16 @@ func getNetworkService() *gigachat.NetworkService {
 	return networkService
 }
 
-func getMCPClient() *mcpclient.MCPClient {
-	mcpClient, err := mcpclient.NewMCPClient("/Users/sergeyusachev/Projects/GoProjects/MCP_Example/mcp_server/myserver")
+func getMCPClients(ctx context.Context) []*mcpclient.MCPClient {
+	config, err := mcpclient.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("MCP config error: ", err)
+		os.Exit(1)
+	}
+	mcpClients, err := mcpclient.ConnectAll(ctx, config, nil)
 	if err != nil {
 		fmt.Println("MCP client creation error: ", err)
 		os.Exit(1)
 	}
-	return mcpClient
-}
\ No newline at end of file
+	return mcpClients
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -29,6 +30,7 @@ func NewClient(timeout time.Duration) *Client {
 
 // Request represents an HTTP request configuration
 type Request struct {
+	Context context.Context // Optional, defaults to context.Background()
 	Method  string
 	URL     string
 	Headers map[string]string
@@ -44,7 +46,12 @@ type Response struct {
 
 // Do executes an HTTP request and returns the response
 func (c *Client) Do(req *Request) (*Response, error) {
-	httpReq, err := http.NewRequest(req.Method, req.URL, req.Body)
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, req.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -0,0 +1,79 @@
+package llm
+
+import "context"
+
+// Roles used in provider-neutral chat messages
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// FinishReason describes why the model stopped generating
+type FinishReason string
+
+const (
+	FinishReasonStop      FinishReason = "stop"
+	FinishReasonLength    FinishReason = "length"
+	FinishReasonToolCalls FinishReason = "tool_calls"
+)
+
+// ToolChoice controls whether the model may call tools
+type ToolChoice string
+
+const (
+	ToolChoiceAuto ToolChoice = "auto" // The model decides; also used when empty
+	ToolChoiceNone ToolChoice = "none" // The model must answer with text
+)
+
+// Message is a single chat message in a provider-neutral form
+type Message struct {
+	Role       string
+	Content    string
+	ToolCalls  []ToolCall // Tool calls requested by the assistant
+	ToolCallID string     // ID of the tool call this message answers (role "tool")
+	Name       string     // Name of the tool this message answers (role "tool")
+}
+
+// Tool describes a function the model is allowed to call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  any // JSON schema as object
+}
+
+// ToolCall is a single function call requested by the model
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments map[string]any
+}
+
+// Request holds everything needed for one chat completion
+type Request struct {
+	Model       string // Empty means the provider's default model
+	Temperature float64
+	Messages    []Message
+	Tools       []Tool
+	ToolChoice  ToolChoice
+}
+
+// Completion is the model's answer to a Request
+type Completion struct {
+	Message      Message
+	FinishReason FinishReason
+}
+
+// Provider is a chat completions backend the agent can talk to
+type Provider interface {
+	Complete(ctx context.Context, req *Request) (*Completion, error)
+}
+
+// StreamingProvider is implemented by providers that can stream generated
+// text. onDelta receives every piece of content as it arrives; the returned
+// completion is the same as Complete would have returned.
+type StreamingProvider interface {
+	Provider
+	CompleteStream(ctx context.Context, req *Request, onDelta func(delta string)) (*Completion, error)
+}
@@ -0,0 +1,129 @@
+package scripted
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"serge.com/mcp-example/llm"
+)
+
+// Provider is an in-process llm.Provider that replays a queue of canned
+// completions and records every request it receives. It is meant for
+// deterministic, offline runs of the agent's tool loop.
+type Provider struct {
+	mu          sync.Mutex
+	completions []*llm.Completion
+	requests    []llm.Request
+	nextCallID  int
+}
+
+// NewProvider creates a provider that returns the given completions in order
+func NewProvider(completions ...*llm.Completion) *Provider {
+	return &Provider{completions: completions}
+}
+
+// Text creates a final answer completion
+func Text(content string) *llm.Completion {
+	return &llm.Completion{
+		Message: llm.Message{
+			Role:    llm.RoleAssistant,
+			Content: content,
+		},
+		FinishReason: llm.FinishReasonStop,
+	}
+}
+
+// ToolCall creates a completion that asks to call a single tool
+func ToolCall(name string, arguments map[string]any) *llm.Completion {
+	return ToolCalls(llm.ToolCall{Name: name, Arguments: arguments})
+}
+
+// ToolCalls creates a completion that asks to call several tools in one turn
+func ToolCalls(toolCalls ...llm.ToolCall) *llm.Completion {
+	return &llm.Completion{
+		Message: llm.Message{
+			Role:      llm.RoleAssistant,
+			ToolCalls: toolCalls,
+		},
+		FinishReason: llm.FinishReasonToolCalls,
+	}
+}
+
+// Enqueue appends completions to the end of the script
+func (p *Provider) Enqueue(completions ...*llm.Completion) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.completions = append(p.completions, completions...)
+}
+
+// Complete records the request and returns the next scripted completion
+func (p *Provider) Complete(ctx context.Context, req *llm.Request) (*llm.Completion, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Copy messages so later appends by the caller don't change the record
+	recorded := *req
+	recorded.Messages = append([]llm.Message(nil), req.Messages...)
+	p.requests = append(p.requests, recorded)
+
+	if len(p.completions) == 0 {
+		return nil, fmt.Errorf("scripted provider: no completion left for request %d", len(p.requests))
+	}
+
+	next := p.completions[0]
+	p.completions = p.completions[1:]
+
+	// Return a copy with tool call IDs filled in, like a real backend would
+	completion := *next
+	completion.Message.ToolCalls = make([]llm.ToolCall, len(next.Message.ToolCalls))
+	for i, toolCall := range next.Message.ToolCalls {
+		if toolCall.ID == "" {
+			p.nextCallID++
+			toolCall.ID = fmt.Sprintf("call_%d", p.nextCallID)
+		}
+		completion.Message.ToolCalls[i] = toolCall
+	}
+
+	return &completion, nil
+}
+
+// Requests returns every request received so far
+func (p *Provider) Requests() []llm.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]llm.Request(nil), p.requests...)
+}
+
+// Remaining returns the number of scripted completions not yet consumed
+func (p *Provider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.completions)
+}
+
+// ToolResults returns the tool messages the agent sent back to the model,
+// taken from the latest request, which carries the whole conversation
+func (p *Provider) ToolResults() []llm.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.requests) == 0 {
+		return nil
+	}
+
+	var results []llm.Message
+	for _, message := range p.requests[len(p.requests)-1].Messages {
+		if message.Role == llm.RoleTool {
+			results = append(results, message)
+		}
+	}
+	return results
+}
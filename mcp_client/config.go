@@ -0,0 +1,239 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Supported values of ServerConfig.Transport
+const (
+	TransportStdio          = "stdio"
+	TransportStreamableHTTP = "http"
+	TransportSSE            = "sse"
+)
+
+// Config lists the MCP servers a client program connects to. The format
+// follows the common "mcpServers" map:
+//
+//	{
+//	  "mcpServers": {
+//	    "files": {
+//	      "command": "./mcp_server/myserver",
+//	      "args": [],
+//	      "env": {"API_KEY": "${API_KEY}"},
+//	      "cwd": ".",
+//	      "transport": "stdio",
+//	      "enabled": true,
+//	      "logLevel": "info",
+//	      "roots": ["./workspace"]
+//	    },
+//	    "shared": {
+//	      "url": "https://tools.example.com/mcp",
+//	      "headers": {"Authorization": "Bearer ${TOOLS_TOKEN}"},
+//	      "transport": "http"
+//	    }
+//	  }
+//	}
+type Config struct {
+	MCPServers map[string]ServerConfig `json:"mcpServers"`
+}
+
+// ServerConfig describes how to start and connect to one MCP server.
+// Values may reference environment variables as $VAR or ${VAR}.
+type ServerConfig struct {
+	// Used by the stdio transport
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Cwd     string            `json:"cwd,omitempty"` // Relative paths are resolved against the config file
+
+	// Used by the http and sse transports
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	Transport string `json:"transport,omitempty"` // "stdio", "http" or "sse"; defaults to "stdio", or "http" if url is set
+	Enabled   *bool  `json:"enabled,omitempty"`   // Defaults to true
+	LogLevel  string `json:"logLevel,omitempty"`  // Minimum level of server log notifications, e.g. "info"
+
+	// Directories advertised to the server as MCP roots. Relative paths are
+	// resolved against the config file.
+	Roots []string `json:"roots,omitempty"`
+
+	baseDir string // Directory of the config file the server was loaded from
+}
+
+// IsEnabled reports whether the server should be started
+func (sc ServerConfig) IsEnabled() bool {
+	return sc.Enabled == nil || *sc.Enabled
+}
+
+// LoadConfig reads a JSON config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse MCP config %s: %w", path, err)
+	}
+
+	baseDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for name, serverConfig := range config.MCPServers {
+		serverConfig.baseDir = baseDir
+		config.MCPServers[name] = serverConfig
+	}
+
+	return &config, nil
+}
+
+// ConnectAll starts and connects to every enabled server, in name order.
+// If any server fails, the ones already connected are closed. opts may be nil.
+func ConnectAll(ctx context.Context, config *Config, opts *Options) ([]*MCPClient, error) {
+	names := make([]string, 0, len(config.MCPServers))
+	for name := range config.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var clients []*MCPClient
+	for _, name := range names {
+		serverConfig := config.MCPServers[name]
+		if !serverConfig.IsEnabled() {
+			continue
+		}
+
+		client, err := NewMCPClientFromConfig(ctx, name, serverConfig, opts)
+		if err != nil {
+			for _, client := range clients {
+				client.Close()
+			}
+			return nil, fmt.Errorf("failed to connect to MCP server %s: %w", name, err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// NewMCPClientFromConfig starts and connects to a single configured server.
+// The client is named after its key in the config. opts may be nil; the
+// server's logLevel and roots take precedence over opts.LogLevel and opts.Roots.
+func NewMCPClientFromConfig(ctx context.Context, name string, serverConfig ServerConfig, opts *Options) (*MCPClient, error) {
+	var client *MCPClient
+	var err error
+
+	serverOpts := Options{}
+	if opts != nil {
+		serverOpts = *opts
+	}
+	if serverConfig.LogLevel != "" {
+		serverOpts.LogLevel = mcp.LoggingLevel(serverConfig.LogLevel)
+	}
+	if len(serverConfig.Roots) > 0 {
+		serverOpts.Roots = nil
+		for _, root := range serverConfig.Roots {
+			serverOpts.Roots = append(serverOpts.Roots, serverConfig.resolvePath(root))
+		}
+	}
+
+	switch serverConfig.transportName() {
+	case TransportStdio:
+		if serverConfig.Command == "" {
+			return nil, fmt.Errorf("command is required for the %s transport", TransportStdio)
+		}
+		newTransport := func(c *MCPClient) mcp.Transport {
+			cmd := serverConfig.command()
+			cmd.Stderr = c.stderrWriter()
+			return &mcp.CommandTransport{Command: cmd}
+		}
+		client, err = connect(ctx, newTransport, name, &serverOpts)
+	case TransportStreamableHTTP:
+		if serverConfig.URL == "" {
+			return nil, fmt.Errorf("url is required for the %s transport", TransportStreamableHTTP)
+		}
+		client, err = NewStreamableHTTPClient(ctx, os.ExpandEnv(serverConfig.URL), serverConfig.headers(), &serverOpts)
+	case TransportSSE:
+		if serverConfig.URL == "" {
+			return nil, fmt.Errorf("url is required for the %s transport", TransportSSE)
+		}
+		client, err = NewSSEClient(ctx, os.ExpandEnv(serverConfig.URL), serverConfig.headers(), &serverOpts)
+	default:
+		return nil, fmt.Errorf("unsupported transport %q", serverConfig.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client.SetName(name)
+
+	return client, nil
+}
+
+func (sc ServerConfig) transportName() string {
+	if sc.Transport != "" {
+		return sc.Transport
+	}
+	if sc.URL != "" {
+		return TransportStreamableHTTP
+	}
+	return TransportStdio
+}
+
+func (sc ServerConfig) headers() map[string]string {
+	headers := make(map[string]string, len(sc.Headers))
+	for key, value := range sc.Headers {
+		headers[key] = os.ExpandEnv(value)
+	}
+	return headers
+}
+
+func (sc ServerConfig) command() *exec.Cmd {
+	// Commands given as relative paths are resolved against the config file,
+	// bare names are looked up in PATH
+	command := os.ExpandEnv(sc.Command)
+	if strings.ContainsRune(command, filepath.Separator) && !filepath.IsAbs(command) {
+		command = filepath.Join(sc.baseDir, command)
+	}
+
+	args := make([]string, len(sc.Args))
+	for i, arg := range sc.Args {
+		args[i] = os.ExpandEnv(arg)
+	}
+
+	cmd := exec.Command(command, args...)
+
+	if sc.Cwd != "" {
+		cmd.Dir = sc.resolvePath(sc.Cwd)
+	}
+
+	if len(sc.Env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range sc.Env {
+			cmd.Env = append(cmd.Env, key+"="+os.ExpandEnv(value))
+		}
+	}
+
+	return cmd
+}
+
+// resolvePath expands environment variables and makes a relative path
+// relative to the config file
+func (sc ServerConfig) resolvePath(path string) string {
+	path = os.ExpandEnv(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(sc.baseDir, path)
+	}
+	return path
+}
@@ -0,0 +1,58 @@
+package mcpclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ConfirmOnTerminal returns an elicitation handler that shows the server's
+// question on out and accepts it when the user answers "y" or "yes" on in.
+// Only plain confirmations are supported; requests that ask the user to fill
+// in fields are declined.
+func ConfirmOnTerminal(in io.Reader, out io.Writer) func(context.Context, *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+	var mu sync.Mutex // One question at a time, servers may ask concurrently
+	reader := bufio.NewReader(in)
+
+	return func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+		if hasRequestedFields(req.Params.RequestedSchema) {
+			return &mcp.ElicitResult{Action: "decline"}, nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(out, "\n%s [y/N] ", req.Params.Message)
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			// No terminal to ask, e.g. stdin was closed
+			return &mcp.ElicitResult{Action: "cancel"}, nil
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return &mcp.ElicitResult{Action: "accept"}, nil
+		default:
+			return &mcp.ElicitResult{Action: "decline"}, nil
+		}
+	}
+}
+
+// hasRequestedFields reports whether an elicitation schema asks for any data
+func hasRequestedFields(schema any) bool {
+	object, ok := schema.(map[string]any)
+	if !ok {
+		return false
+	}
+	properties, ok := object["properties"].(map[string]any)
+	return ok && len(properties) > 0
+}
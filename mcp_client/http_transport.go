@@ -0,0 +1,71 @@
+package mcpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// NewStreamableHTTPClient connects to a remote MCP server over the streamable
+// HTTP transport. headers (e.g. "Authorization": "Bearer ...") are sent with
+// every request. opts may be nil.
+func NewStreamableHTTPClient(ctx context.Context, endpoint string, headers map[string]string, opts *Options) (*MCPClient, error) {
+	httpClient := newHeaderHTTPClient(headers)
+	newTransport := func(*MCPClient) mcp.Transport {
+		return &mcp.StreamableClientTransport{
+			Endpoint:   endpoint,
+			HTTPClient: httpClient,
+		}
+	}
+	return connect(ctx, newTransport, endpointHost(endpoint), opts)
+}
+
+// NewSSEClient connects to a remote MCP server over the legacy HTTP+SSE
+// transport. headers are sent with every request. opts may be nil.
+func NewSSEClient(ctx context.Context, endpoint string, headers map[string]string, opts *Options) (*MCPClient, error) {
+	httpClient := newHeaderHTTPClient(headers)
+	newTransport := func(*MCPClient) mcp.Transport {
+		return &mcp.SSEClientTransport{
+			Endpoint:   endpoint,
+			HTTPClient: httpClient,
+		}
+	}
+	return connect(ctx, newTransport, endpointHost(endpoint), opts)
+}
+
+// headerRoundTripper adds static headers to every outgoing request
+type headerRoundTripper struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for key, value := range rt.headers {
+		req.Header.Set(key, value)
+	}
+	return rt.next.RoundTrip(req)
+}
+
+func newHeaderHTTPClient(headers map[string]string) *http.Client {
+	if len(headers) == 0 {
+		return http.DefaultClient
+	}
+	return &http.Client{
+		Transport: &headerRoundTripper{
+			headers: headers,
+			next:    http.DefaultTransport,
+		},
+	}
+}
+
+func endpointHost(endpoint string) string {
+	parsedURL, err := url.Parse(endpoint)
+	if err != nil || parsedURL.Host == "" {
+		return endpoint
+	}
+	return parsedURL.Host
+}
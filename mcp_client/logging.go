@@ -0,0 +1,94 @@
+package mcpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// handleLoggingMessage forwards a server log notification to the logger
+func (c *MCPClient) handleLoggingMessage(ctx context.Context, req *mcp.LoggingMessageRequest) {
+	params := req.Params
+
+	attrs := []any{"server", c.Name(), "source", "notification"}
+	if params.Logger != "" {
+		attrs = append(attrs, "logger", params.Logger)
+	}
+
+	// Data may be any JSON value. Strings are logged as they are, objects
+	// written by slog-based servers keep their "msg" as the message.
+	message, ok := params.Data.(string)
+	if data, isObject := params.Data.(map[string]any); isObject {
+		if message, ok = data["msg"].(string); ok {
+			for key, value := range data {
+				if key != "msg" && key != "time" && key != "level" {
+					attrs = append(attrs, key, value)
+				}
+			}
+		}
+	}
+	if !ok {
+		dataJSON, err := json.Marshal(params.Data)
+		if err != nil {
+			return
+		}
+		message = string(dataJSON)
+	}
+
+	c.logger.Log(ctx, slogLevel(params.Level), message, attrs...)
+}
+
+// stderrWriter returns a writer that logs the server's stderr line by line
+func (c *MCPClient) stderrWriter() *lineLogger {
+	return &lineLogger{client: c}
+}
+
+// lineLogger logs every complete line written to it, keeping partial lines
+// until the rest arrives
+type lineLogger struct {
+	client *MCPClient
+	mu     sync.Mutex
+	buffer []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buffer = append(l.buffer, p...)
+	for {
+		i := bytes.IndexByte(l.buffer, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(bytes.TrimRight(l.buffer[:i], "\r"))
+		l.buffer = l.buffer[i+1:]
+		if line != "" {
+			l.client.logger.Info(line, "server", l.client.Name(), "source", "stderr")
+		}
+	}
+
+	return len(p), nil
+}
+
+var mcpToSlogLevel = map[mcp.LoggingLevel]slog.Level{
+	"debug":     mcp.LevelDebug,
+	"info":      mcp.LevelInfo,
+	"notice":    mcp.LevelNotice,
+	"warning":   mcp.LevelWarning,
+	"error":     mcp.LevelError,
+	"critical":  mcp.LevelCritical,
+	"alert":     mcp.LevelAlert,
+	"emergency": mcp.LevelEmergency,
+}
+
+func slogLevel(level mcp.LoggingLevel) slog.Level {
+	if sl, ok := mcpToSlogLevel[level]; ok {
+		return sl
+	}
+	return slog.LevelInfo
+}
@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -27,38 +31,172 @@ type ToolInfo struct {
 	InputSchema string
 }
 
+// ToolError is returned by CallTool when the tool itself reports a failure
+type ToolError struct {
+	ToolName string
+	Message  string // Text content of the failed result, if any
+}
+
+func (e *ToolError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("tool %s returned an error", e.ToolName)
+	}
+	return fmt.Sprintf("tool %s returned an error: %s", e.ToolName, e.Message)
+}
+
+// Options configures an MCPClient. The zero value is ready to use.
+type Options struct {
+	// Logger receives the server's stderr output and its log notifications,
+	// tagged with the server name. Defaults to slog.Default().
+	Logger *slog.Logger
+	// LogLevel is the minimum level of log notifications requested from the
+	// server. Empty leaves the server's default in place.
+	LogLevel mcp.LoggingLevel
+	// Roots are the directories the server may work in, advertised to it as
+	// MCP roots. They can be changed later with SetRoots.
+	Roots []string
+	// ElicitationHandler answers the server's requests for user input, such
+	// as confirming a destructive tool call. When nil the client doesn't
+	// advertise elicitation and servers can't ask.
+	ElicitationHandler func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error)
+}
+
+// transportFactory creates a fresh transport for every (re)connect
+type transportFactory func(c *MCPClient) mcp.Transport
+
 type MCPClient struct {
-	ctx     context.Context
-	session *mcp.ClientSession
+	nameMu       sync.RWMutex // Separate from mu, the name is read while mu is held
+	name         string
+	rootsMu      sync.Mutex
+	roots        []*mcp.Root
+	offersRoots  bool // Whether roots were ever set, see SetRoots
+	client       *mcp.Client
+	newTransport transportFactory
+	logger       *slog.Logger
+	logLevel     mcp.LoggingLevel
+
+	mu           sync.Mutex // Guards the fields below
+	session      *mcp.ClientSession
+	sessionDone  chan struct{} // Closed when the session's connection is gone
+	generation   int           // Number of sessions so far
+	reconnecting chan struct{} // Closed when the running reconnect ends, nil if none
+	closed       bool
+}
+
+// NewMCPClient starts the server binary and connects to it over stdin/stdout.
+// opts may be nil.
+func NewMCPClient(ctx context.Context, serverPath string, opts *Options) (*MCPClient, error) {
+	newTransport := func(c *MCPClient) mcp.Transport {
+		cmd := exec.Command(serverPath)
+		cmd.Stderr = c.stderrWriter()
+		return &mcp.CommandTransport{Command: cmd}
+	}
+	return connect(ctx, newTransport, filepath.Base(serverPath), opts)
 }
 
-func NewMCPClient(serverPath string) (*MCPClient, error) {
-	ctx := context.Background()
+// connect starts a session over a transport made by newTransport. The client
+// is named after the server it talks to, or fallbackName if the server
+// doesn't report a name.
+func connect(ctx context.Context, newTransport transportFactory, fallbackName string, opts *Options) (*MCPClient, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	c := &MCPClient{
+		name:         fallbackName,
+		newTransport: newTransport,
+		logger:       opts.Logger,
+		logLevel:     opts.LogLevel,
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+
+	// Create a new client that forwards the server's log notifications.
+	c.client = mcp.NewClient(&mcp.Implementation{Name: "mcp-client", Version: "v1.0.0"}, &mcp.ClientOptions{
+		LoggingMessageHandler: c.handleLoggingMessage,
+		ElicitationHandler:    opts.ElicitationHandler,
+	})
+	c.client.AddReceivingMiddleware(c.serveRoots)
+	if err := c.SetRoots(opts.Roots); err != nil {
+		return nil, err
+	}
+
+	session, err := c.startSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.setSession(session)
+
+	if initResult := session.InitializeResult(); initResult != nil && initResult.ServerInfo != nil && initResult.ServerInfo.Name != "" {
+		c.SetName(initResult.ServerInfo.Name)
+	}
 
-	// Create a new client, with no features.
-	client := mcp.NewClient(&mcp.Implementation{Name: "mcp-client", Version: "v1.0.0"}, nil)
+	return c, nil
+}
 
-	// Connect to a server over stdin/stdout.
-	transport := &mcp.CommandTransport{Command: exec.Command(serverPath)}
-	session, err := client.Connect(ctx, transport, nil)
+// startSession connects a new session, which also runs initialization, and
+// subscribes to the server's log notifications
+func (c *MCPClient) startSession(ctx context.Context) (*mcp.ClientSession, error) {
+	session, err := c.client.Connect(ctx, c.newTransport(c), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return &MCPClient{
-		ctx:     ctx,
-		session: session,
-	}, nil
+	if c.logLevel != "" {
+		initResult := session.InitializeResult()
+		if initResult == nil || initResult.Capabilities == nil || initResult.Capabilities.Logging == nil {
+			c.logger.Warn("MCP server does not support logging", "server", c.Name())
+		} else if err := session.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: c.logLevel}); err != nil {
+			c.logger.Warn("failed to set MCP server log level", "server", c.Name(), "level", c.logLevel, "error", err)
+		}
+	}
+
+	return session, nil
+}
+
+// Name returns the name used to tell this server apart from others
+func (c *MCPClient) Name() string {
+	c.nameMu.RLock()
+	defer c.nameMu.RUnlock()
+
+	return c.name
+}
+
+// SetName overrides the name reported by the server
+func (c *MCPClient) SetName(name string) {
+	c.nameMu.Lock()
+	defer c.nameMu.Unlock()
+
+	c.name = name
 }
 
 func (c *MCPClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
 	c.session.Close()
 }
 
-func (c *MCPClient) CallToolsList() ([]ToolInfo, error) {
+func (c *MCPClient) CallToolsList(ctx context.Context) ([]ToolInfo, error) {
 	var tools []ToolInfo
+	err := c.withSession(ctx, true, func(session *mcp.ClientSession) error {
+		var err error
+		tools, err = listTools(ctx, session)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	for tool, err := range c.session.Tools(c.ctx, nil) {
+	return tools, nil
+}
+
+func listTools(ctx context.Context, session *mcp.ClientSession) ([]ToolInfo, error) {
+	var tools []ToolInfo
+
+	for tool, err := range session.Tools(ctx, nil) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to list tools: %w", err)
 		}
@@ -82,7 +220,7 @@ func (c *MCPClient) CallToolsList() ([]ToolInfo, error) {
 	return tools, nil
 }
 
-func (c *MCPClient) CallGreeting(name string, secondName string) {
+func (c *MCPClient) CallGreeting(ctx context.Context, name string, secondName string) {
 	fmt.Println("\n=== Calling Greeting Tool ===")
 
 	params := &mcp.CallToolParams{
@@ -93,7 +231,7 @@ func (c *MCPClient) CallGreeting(name string, secondName string) {
 		},
 	}
 
-	res, err := c.session.CallTool(c.ctx, params)
+	res, err := c.currentSession().CallTool(ctx, params)
 	if err != nil {
 		log.Fatalf("CallTool failed: %v", err)
 	}
@@ -112,7 +250,7 @@ func (c *MCPClient) CallGreeting(name string, secondName string) {
 	}
 }
 
-func (c *MCPClient) CallCryptoCurrency(coinID string, currency string) {
+func (c *MCPClient) CallCryptoCurrency(ctx context.Context, coinID string, currency string) {
 	fmt.Println("\n=== Calling Crypto Price Tool ===")
 
 	params := &mcp.CallToolParams{
@@ -123,7 +261,7 @@ func (c *MCPClient) CallCryptoCurrency(coinID string, currency string) {
 		},
 	}
 
-	res, err := c.session.CallTool(c.ctx, params)
+	res, err := c.currentSession().CallTool(ctx, params)
 	if err != nil {
 		log.Fatalf("CallTool failed: %v", err)
 	}
@@ -150,13 +288,26 @@ func (c *MCPClient) CallTool(ctx context.Context, toolName string, arguments map
 		Arguments: arguments,
 	}
 
-	result, err := c.session.CallTool(ctx, params)
+	var result *mcp.CallToolResult
+	// Tools may change things, so a call cut off by a dropped connection is
+	// not sent again
+	err := c.withSession(ctx, false, func(session *mcp.ClientSession) error {
+		var err error
+		result, err = session.CallTool(ctx, params)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to call tool %s: %w", toolName, err)
 	}
 
 	if result.IsError {
-		return nil, fmt.Errorf("tool %s returned an error", toolName)
+		var message strings.Builder
+		for _, content := range result.Content {
+			if textContent, ok := content.(*mcp.TextContent); ok {
+				message.WriteString(textContent.Text)
+			}
+		}
+		return nil, &ToolError{ToolName: toolName, Message: message.String()}
 	}
 
 	return result, nil
@@ -0,0 +1,221 @@
+package mcpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	reconnectAttempts     = 5
+	reconnectInitialDelay = 500 * time.Millisecond
+	reconnectMaxDelay     = 8 * time.Second
+)
+
+// ReconnectError is returned when the session died and could not be
+// re-established after several attempts
+type ReconnectError struct {
+	ServerName string
+	Attempts   int
+	Err        error // Error of the last attempt
+}
+
+func (e *ReconnectError) Error() string {
+	return fmt.Sprintf("MCP server %s is down, reconnection failed after %d attempts: %v", e.ServerName, e.Attempts, e.Err)
+}
+
+func (e *ReconnectError) Unwrap() error {
+	return e.Err
+}
+
+// setSession makes session the current one and starts watching for its end.
+// Must be called with c.mu held, or before c is shared.
+func (c *MCPClient) setSession(session *mcp.ClientSession) {
+	done := make(chan struct{})
+	go func() {
+		session.Wait()
+		close(done)
+	}()
+
+	c.session = session
+	c.sessionDone = done
+	c.generation++
+}
+
+func (c *MCPClient) currentSession() *mcp.ClientSession {
+	session, _ := c.sessionState()
+	return session
+}
+
+// sessionState returns the current session and the channel closed when it ends
+func (c *MCPClient) sessionState() (*mcp.ClientSession, chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.session, c.sessionDone
+}
+
+// withSession runs fn on the current session, reconnecting first if the
+// session is dead. If the connection drops during fn, the server is
+// reconnected for later calls and fn is retried once only when retry is set:
+// the server may already have handled the request, so only read-only calls
+// can safely be sent again.
+func (c *MCPClient) withSession(ctx context.Context, retry bool, fn func(session *mcp.ClientSession) error) error {
+	session, done := c.sessionState()
+	if isClosed(done) {
+		if _, err := c.reconnect(ctx, session); err != nil {
+			return err
+		}
+		session, done = c.sessionState()
+	}
+
+	err := fn(session)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if !errors.Is(err, mcp.ErrConnectionClosed) && !isClosed(done) {
+		return err
+	}
+
+	newSession, reconnectErr := c.reconnect(ctx, session)
+	if !retry {
+		// Keep the reconnect failure too, so callers can tell the server is down
+		lostErr := fmt.Errorf("connection lost, the request may or may not have been handled: %w", err)
+		return errors.Join(lostErr, reconnectErr)
+	}
+	if reconnectErr != nil {
+		return reconnectErr
+	}
+	return fn(newSession)
+}
+
+// reconnect replaces the dead session with a new one, retrying with
+// exponential backoff. If another caller already replaced deadSession,
+// the current session is returned instead. Only one caller reconnects at a
+// time; the others wait for it or for their ctx. c.mu is not held while
+// connecting, so Close and other calls aren't blocked by the backoff.
+func (c *MCPClient) reconnect(ctx context.Context, deadSession *mcp.ClientSession) (*mcp.ClientSession, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("MCP client %s is closed", c.Name())
+		}
+		if c.session != deadSession {
+			session := c.session
+			c.mu.Unlock()
+			return session, nil
+		}
+		if running := c.reconnecting; running != nil {
+			c.mu.Unlock()
+			// Try again, or reconnect ourselves if the other attempt failed
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-running:
+			}
+			continue
+		}
+		finished := make(chan struct{})
+		c.reconnecting = finished
+		c.mu.Unlock()
+
+		session, err := c.restartWithBackoff(ctx, deadSession)
+
+		c.mu.Lock()
+		c.reconnecting = nil
+		close(finished)
+		if err == nil {
+			if c.closed {
+				session.Close()
+				err = fmt.Errorf("MCP client %s is closed", c.Name())
+			} else {
+				c.setSession(session)
+			}
+		}
+		c.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+		return session, nil
+	}
+}
+
+// restartWithBackoff starts a new session in place of deadSession, retrying
+// with exponential backoff
+func (c *MCPClient) restartWithBackoff(ctx context.Context, deadSession *mcp.ClientSession) (*mcp.ClientSession, error) {
+	// Release whatever is left of the old connection, e.g. a crashed subprocess
+	deadSession.Close()
+
+	delay := reconnectInitialDelay
+	var lastErr error
+	for attempt := 1; attempt <= reconnectAttempts; attempt++ {
+		c.mu.Lock()
+		closed := c.closed
+		c.mu.Unlock()
+		if closed {
+			return nil, fmt.Errorf("MCP client %s is closed", c.Name())
+		}
+
+		c.logger.Warn("reconnecting to MCP server", "server", c.Name(), "attempt", attempt, "max_attempts", reconnectAttempts)
+
+		session, tools, err := c.restartSession(ctx)
+		if err == nil {
+			c.logger.Info("reconnected to MCP server", "server", c.Name(), "tools", len(tools))
+			return session, nil
+		}
+		lastErr = err
+
+		if attempt == reconnectAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+	}
+
+	return nil, &ReconnectError{ServerName: c.Name(), Attempts: reconnectAttempts, Err: lastErr}
+}
+
+// restartSession starts a new session and lists its tools to make sure the
+// server is actually usable. Tools may have changed with the restart, which
+// callers notice through Generation.
+func (c *MCPClient) restartSession(ctx context.Context) (*mcp.ClientSession, []ToolInfo, error) {
+	session, err := c.startSession(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tools, err := listTools(ctx, session)
+	if err != nil {
+		session.Close()
+		return nil, nil, err
+	}
+
+	return session, tools, nil
+}
+
+// Generation counts the sessions the client has had. It changes whenever the
+// server was reconnected, so tools listed before may be out of date.
+func (c *MCPClient) Generation() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+func isClosed(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,49 @@
+package mcpclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ListResources returns the resources the server offers. Servers without
+// resources return none.
+func (c *MCPClient) ListResources(ctx context.Context) ([]*mcp.Resource, error) {
+	var resources []*mcp.Resource
+	err := c.withSession(ctx, true, func(session *mcp.ClientSession) error {
+		initResult := session.InitializeResult()
+		if initResult == nil || initResult.Capabilities == nil || initResult.Capabilities.Resources == nil {
+			return nil
+		}
+
+		resources = nil // Start over if the call is retried after a reconnect
+		for resource, err := range session.Resources(ctx, nil) {
+			if err != nil {
+				return err
+			}
+			resources = append(resources, resource)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resources: %w", err)
+	}
+
+	return resources, nil
+}
+
+// ReadResource reads the resource with the given URI
+func (c *MCPClient) ReadResource(ctx context.Context, uri string) (*mcp.ReadResourceResult, error) {
+	var result *mcp.ReadResourceResult
+	err := c.withSession(ctx, true, func(session *mcp.ClientSession) error {
+		var err error
+		result, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource %s: %w", uri, err)
+	}
+
+	return result, nil
+}
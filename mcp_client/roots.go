@@ -0,0 +1,95 @@
+package mcpclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// SetRoots replaces the directories advertised to the server as MCP roots.
+// Connected servers get a single notification that the roots list changed,
+// and see either the old list or the new one, never a mix.
+//
+// A client that never had roots doesn't answer roots/list, so the server
+// uses its own roots. Once roots were set, an empty list leaves the server
+// with no directories at all.
+func (c *MCPClient) SetRoots(paths []string) error {
+	roots, err := toRoots(paths)
+	if err != nil {
+		return err
+	}
+
+	c.rootsMu.Lock()
+	defer c.rootsMu.Unlock()
+
+	if len(roots) == 0 && !c.offersRoots {
+		return nil
+	}
+	oldRoots := c.roots
+	c.roots = roots
+	c.offersRoots = true
+
+	// roots/list is answered by serveRoots from c.roots. The SDK's own list
+	// is only used to send the notification, since replacing it takes a
+	// remove and an add that notify separately.
+	if len(roots) > 0 {
+		c.client.AddRoots(roots...)
+	} else {
+		oldURIs := make([]string, 0, len(oldRoots))
+		for _, root := range oldRoots {
+			oldURIs = append(oldURIs, root.URI)
+		}
+		c.client.RemoveRoots(oldURIs...)
+	}
+
+	return nil
+}
+
+// serveRoots answers roots/list with the current roots
+func (c *MCPClient) serveRoots(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if _, ok := req.(*mcp.ListRootsRequest); !ok {
+			return next(ctx, method, req)
+		}
+
+		c.rootsMu.Lock()
+		defer c.rootsMu.Unlock()
+
+		if !c.offersRoots {
+			return nil, fmt.Errorf("the client has no roots")
+		}
+		return &mcp.ListRootsResult{Roots: append([]*mcp.Root{}, c.roots...)}, nil
+	}
+}
+
+// Roots returns the directories advertised to the server
+func (c *MCPClient) Roots() []string {
+	c.rootsMu.Lock()
+	defer c.rootsMu.Unlock()
+
+	paths := make([]string, 0, len(c.roots))
+	for _, root := range c.roots {
+		parsedURL, _ := url.Parse(root.URI)
+		paths = append(paths, filepath.FromSlash(parsedURL.Path))
+	}
+	return paths
+}
+
+func toRoots(paths []string) ([]*mcp.Root, error) {
+	roots := make([]*mcp.Root, 0, len(paths))
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %s: %w", path, err)
+		}
+		rootURL := url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}
+		roots = append(roots, &mcp.Root{
+			URI:  rootURL.String(),
+			Name: filepath.Base(absPath),
+		})
+	}
+	return roots, nil
+}
@@ -0,0 +1,74 @@
+package mcpclient
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// rootsRecorder is a server that lists the client's roots on every
+// roots/list_changed notification
+type rootsRecorder struct {
+	mu    sync.Mutex
+	lists [][]string
+	seen  chan struct{}
+}
+
+func (r *rootsRecorder) handleRootsListChanged(ctx context.Context, req *mcp.RootsListChangedRequest) {
+	result, err := req.Session.ListRoots(ctx, nil)
+
+	r.mu.Lock()
+	var uris []string
+	if err == nil {
+		for _, root := range result.Roots {
+			uris = append(uris, root.URI)
+		}
+	}
+	r.lists = append(r.lists, uris)
+	r.mu.Unlock()
+	r.seen <- struct{}{}
+}
+
+func TestSetRootsNotifiesOnce(t *testing.T) {
+	recorder := &rootsRecorder{seen: make(chan struct{}, 10)}
+	server := mcp.NewServer(&mcp.Implementation{Name: "test"}, &mcp.ServerOptions{RootsListChangedHandler: recorder.handleRootsListChanged})
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(context.Background(), serverTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverSession.Close()
+
+	dir := t.TempDir()
+	client, err := connect(context.Background(), func(*MCPClient) mcp.Transport { return clientTransport }, "test", &Options{Roots: []string{dir + "/old"}})
+	if err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer client.Close()
+
+	if err := client.SetRoots([]string{dir + "/new"}); err != nil {
+		t.Fatalf("SetRoots() error = %v", err)
+	}
+	select {
+	case <-recorder.seen:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no roots/list_changed notification")
+	}
+	// Give a second notification, if any, time to arrive
+	select {
+	case <-recorder.seen:
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.lists) != 1 {
+		t.Fatalf("server got %d notifications listing %q, want 1", len(recorder.lists), recorder.lists)
+	}
+	if got := recorder.lists[0]; len(got) != 1 || got[0] != "file://"+dir+"/new" {
+		t.Errorf("roots after the change = %q, want only the new root", got)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrNotConfirmed is returned when a destructive operation wasn't approved
+var ErrNotConfirmed = errors.New("operation was not confirmed by the user")
+
+// confirm asks the user of the calling client to approve a destructive
+// operation, using an MCP elicitation request. Clients that can't ask their
+// user are refused, so nothing is destroyed without a human saying yes.
+func confirm(ctx context.Context, req *mcp.CallToolRequest, message string) error {
+	if req == nil || req.Session == nil {
+		return fmt.Errorf("%w: no client session", ErrNotConfirmed)
+	}
+
+	params := req.Session.InitializeParams()
+	if params == nil || params.Capabilities == nil || params.Capabilities.Elicitation == nil {
+		return fmt.Errorf("%w: the client doesn't support elicitation", ErrNotConfirmed)
+	}
+
+	result, err := req.Session.Elicit(ctx, &mcp.ElicitParams{
+		Message: message,
+		// Nothing to fill in, accepting is the confirmation
+		RequestedSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ask for confirmation: %w", err)
+	}
+	if result.Action != "accept" {
+		return fmt.Errorf("%w: the user chose %s", ErrNotConfirmed, result.Action)
+	}
+
+	return nil
+}
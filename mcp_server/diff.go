@@ -0,0 +1,461 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+const diffContextLines = 3
+
+// diffOp is one line of a line diff: ' ' kept, '-' removed or '+' added.
+// aPos and bPos are the positions of the line in the old and new text.
+type diffOp struct {
+	kind byte
+	text string
+	aPos int
+	bPos int
+}
+
+// splitLines splits text into lines that keep their line endings
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxDiffCost bounds the edit steps searched for a minimal diff of one part
+// of the texts. Parts that differ more are shown as removed and re-added in
+// full, which keeps large rewrites fast.
+const maxDiffCost = 1000
+
+// diffLines returns an edit script turning a into b. It uses the linear-space
+// form of Myers' algorithm, so memory stays proportional to the input.
+func diffLines(a []string, b []string) []diffOp {
+	d := &differ{a: a, b: b, ops: make([]diffOp, 0, max(len(a), len(b)))}
+	d.diff(0, len(a), 0, len(b))
+	return d.ops
+}
+
+// differ collects the edit script of a and b
+type differ struct {
+	a   []string
+	b   []string
+	ops []diffOp
+}
+
+// diff adds the edit script turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) diff(aLo int, aHi int, bLo int, bHi int) {
+	// Common prefix and suffix never take part in the search
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, diffOp{kind: ' ', text: d.a[aLo], aPos: aLo, bPos: bLo})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-1-suffix] == d.b[bHi-1-suffix] {
+		suffix++
+	}
+	aHi -= suffix
+	bHi -= suffix
+
+	if aLo < aHi && bLo < bHi {
+		if x, y, ok := d.split(aLo, aHi, bLo, bHi); ok {
+			d.diff(aLo, x, bLo, y)
+			d.diff(x, aHi, y, bHi)
+		} else {
+			d.replace(aLo, aHi, bLo, bHi)
+		}
+	} else {
+		d.replace(aLo, aHi, bLo, bHi)
+	}
+
+	for i := range suffix {
+		d.ops = append(d.ops, diffOp{kind: ' ', text: d.a[aHi+i], aPos: aHi + i, bPos: bHi + i})
+	}
+}
+
+// replace removes a[aLo:aHi] and adds b[bLo:bHi]
+func (d *differ) replace(aLo int, aHi int, bLo int, bHi int) {
+	for i := aLo; i < aHi; i++ {
+		d.ops = append(d.ops, diffOp{kind: '-', text: d.a[i], aPos: i, bPos: bLo})
+	}
+	for j := bLo; j < bHi; j++ {
+		d.ops = append(d.ops, diffOp{kind: '+', text: d.b[j], aPos: aHi, bPos: j})
+	}
+}
+
+// split finds a point on a shortest edit path through a[aLo:aHi] and
+// b[bLo:bHi] by searching forward from the start and backward from the end
+// until the two searches overlap. It fails when the parts differ by more
+// than maxDiffCost steps in each direction.
+func (d *differ) split(aLo int, aHi int, bLo int, bHi int) (int, int, bool) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	front := delta%2 != 0 // Whether the forward search meets the backward one
+
+	maxSteps := min((n+m+1)/2, maxDiffCost)
+	offset := maxSteps + 1
+	// forward[offset+k] is the furthest x reached on diagonal k = x - y;
+	// backward holds the same for the search from the end, with x and y
+	// counted from aHi and bHi
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	// Diagonals that left the grid are not searched again
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+
+	for steps := 0; steps < maxSteps; steps++ {
+		for k := -steps + fStart; k <= steps-fEnd; k += 2 {
+			var x int
+			if k == -steps || (k != steps && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case front:
+				if c := offset + delta - k; c >= 0 && c < len(backward) && backward[c] != -1 && x >= n-backward[c] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+
+		for k := -steps + bStart; k <= steps-bEnd; k += 2 {
+			var x int
+			if k == -steps || (k != steps && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !front:
+				if c := offset + delta - k; c >= 0 && c < len(forward) && forward[c] != -1 && forward[c] >= n-x {
+					fx := forward[c]
+					return aLo + fx, bLo + fx - (c - offset), true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// unifiedDiff renders the changes between two texts as a unified diff.
+// It returns "" when the texts are equal.
+func unifiedDiff(name string, oldText string, newText string) (diff string, added int, removed int) {
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var changes []int
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return "", 0, 0
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+
+	for i := 0; i < len(changes); {
+		// Changes closer than twice the context share a hunk
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j]-1 <= 2*diffContextLines {
+			j++
+		}
+		start := max(changes[i]-diffContextLines, 0)
+		end := min(changes[j]+diffContextLines+1, len(ops))
+		writeHunk(&out, ops[start:end])
+		i = j + 1
+	}
+
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return out.String(), added, removed
+}
+
+func writeHunk(out *strings.Builder, ops []diffOp) {
+	oldCount, newCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	// An empty range starts at the line before it
+	oldStart, newStart := ops[0].aPos, ops[0].bPos
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.text)
+		if !strings.HasSuffix(op.text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// filePatch is a unified diff for one file
+type filePatch struct {
+	path  string // Name in the +++ header, empty when the patch has no headers
+	hunks []hunk
+}
+
+// hunk is one "@@" section of a unified diff
+type hunk struct {
+	oldStart int // 1-based line the hunk claims to start at
+	oldCount int // Context and removed lines, from the header
+	newCount int // Context and added lines, from the header
+	lines    []hunkLine
+}
+
+type hunkLine struct {
+	kind      byte // ' ', '-' or '+'
+	text      string
+	noNewline bool // Followed by "\ No newline at end of file"
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// parsePatch reads a unified diff for a single file. The line counts in each
+// hunk header bound the hunk, so a removed "-- x" or added "++ y" line is
+// never taken for a file header. Lines before the first header, like
+// "diff --git", are ignored.
+func parsePatch(patch string) (*filePatch, error) {
+	patch = strings.TrimSuffix(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	lines := strings.Split(patch, "\n")
+	result := &filePatch{}
+	headers := 0
+	var current *hunk
+	oldLeft, newLeft := 0, 0 // Lines of the current hunk still to come
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if oldLeft > 0 || newLeft > 0 {
+			kind := byte(' ')
+			text := ""
+			switch {
+			case line == "":
+				// Some tools strip the space of empty context lines
+			case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+				kind, text = line[0], line[1:]
+			case line[0] == '\\':
+				if len(current.lines) > 0 {
+					current.lines[len(current.lines)-1].noNewline = true
+				}
+				continue
+			default:
+				return nil, fmt.Errorf("hunk %d: line %d of the patch is not a context, removed or added line: %q", len(result.hunks), i+1, line)
+			}
+
+			if kind != '+' {
+				oldLeft--
+			}
+			if kind != '-' {
+				newLeft--
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("hunk %d has more lines than its header counts (%d old, %d new)", len(result.hunks), current.oldCount, current.newCount)
+			}
+			current.lines = append(current.lines, hunkLine{kind: kind, text: text})
+			continue
+		}
+
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			result.hunks = append(result.hunks, hunk{
+				oldStart: atoiDefault(m[1], 0),
+				oldCount: atoiDefault(m[2], 1),
+				newCount: atoiDefault(m[3], 1),
+			})
+			current = &result.hunks[len(result.hunks)-1]
+			oldLeft, newLeft = current.oldCount, current.newCount
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			headers++
+			if headers > 1 {
+				return nil, fmt.Errorf("the patch changes more than one file; send a separate patch for each file")
+			}
+			result.path = patchHeaderPath(line[4:], lines[i+1][4:])
+			i++
+		case strings.HasPrefix(line, "\\") && current != nil && len(current.lines) > 0:
+			current.lines[len(current.lines)-1].noNewline = true
+		case current == nil || line == "" || strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index "):
+			// Text before the first hunk, blank lines and git headers
+		default:
+			return nil, fmt.Errorf("line %d of the patch is not part of a hunk: %q", i+1, line)
+		}
+	}
+
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, fmt.Errorf("hunk %d ends early: its header counts %d old and %d new lines, %d and %d are missing",
+			len(result.hunks), current.oldCount, current.newCount, oldLeft, newLeft)
+	}
+	if len(result.hunks) == 0 {
+		return nil, fmt.Errorf("the patch contains no hunks")
+	}
+	return result, nil
+}
+
+// patchHeaderPath returns the file named by the --- and +++ headers, without
+// a timestamp. The +++ name is used unless the file is being deleted.
+func patchHeaderPath(oldName string, newName string) string {
+	name := newName
+	if strings.HasPrefix(name, "/dev/null") {
+		name = oldName
+	}
+	name, _, _ = strings.Cut(name, "\t")
+	return strings.TrimSpace(name)
+}
+
+func atoiDefault(s string, value int) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return value
+}
+
+// applyPatch applies the hunks of a unified diff to text. Each hunk must
+// match either at the line it names or at exactly one other place.
+func applyPatch(text string, hunks []hunk) (string, error) {
+	lines := splitLines(text)
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+
+	var result []string
+	pos := 0   // Next line of the old text to copy
+	delta := 0 // How far the hunks applied so far have moved the text
+
+	for n, h := range hunks {
+		var old []string
+		for _, line := range h.lines {
+			if line.kind != '+' {
+				old = append(old, line.text)
+			}
+		}
+
+		// A hunk that only adds lines names the line it inserts after
+		start := h.oldStart - 1
+		if len(old) == 0 {
+			start = h.oldStart
+		}
+
+		at, err := locateHunk(lines, old, pos, start+delta)
+		if err != nil {
+			return "", fmt.Errorf("hunk %d (line %d): %w", n+1, h.oldStart, err)
+		}
+		delta = at - start
+
+		result = append(result, lines[pos:at]...)
+		pos = at
+		for _, line := range h.lines {
+			switch line.kind {
+			case ' ':
+				result = append(result, lines[pos])
+				pos++
+			case '-':
+				pos++
+			case '+':
+				if line.noNewline {
+					result = append(result, line.text)
+				} else {
+					result = append(result, line.text+newline)
+				}
+			}
+		}
+	}
+
+	result = append(result, lines[pos:]...)
+	return strings.Join(result, ""), nil
+}
+
+// locateHunk finds where the old lines of a hunk are in the text, at or
+// after from. The expected position wins; otherwise the match must be unique.
+func locateHunk(lines []string, old []string, from int, expected int) (int, error) {
+	matchesAt := func(at int) bool {
+		if at < from || at+len(old) > len(lines) {
+			return false
+		}
+		for i, text := range old {
+			if strings.TrimRight(lines[at+i], "\r\n") != strings.TrimRight(text, "\r\n") {
+				return false
+			}
+		}
+		return true
+	}
+
+	if matchesAt(expected) {
+		return expected, nil
+	}
+	if len(old) == 0 {
+		return 0, fmt.Errorf("line %d is outside the file", expected+1)
+	}
+
+	var found []int
+	for at := from; at+len(old) <= len(lines); at++ {
+		if matchesAt(at) {
+			found = append(found, at)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("context and removed lines don't match the file")
+	case 1:
+		return found[0], nil
+	default:
+		return 0, fmt.Errorf("context matches %d places, none at the stated line; add more context", len(found))
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+	}{
+		{
+			name:    "equal",
+			oldText: "a\nb\n",
+			newText: "a\nb\n",
+			want:    "",
+		},
+		{
+			name:    "changed line",
+			oldText: "a\nb\nc\n",
+			newText: "a\nB\nc\n",
+			want:    "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "insert into empty file",
+			oldText: "",
+			newText: "a\n",
+			want:    "--- a/f\n+++ b/f\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name:    "missing final newline",
+			oldText: "a\nb",
+			newText: "a\nb\n",
+			want:    "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name:    "separate hunks",
+			oldText: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			newText: "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, _ := unifiedDiff("f", tt.oldText, tt.newText)
+			if got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffLargeRewrite(t *testing.T) {
+	const lines = 20000
+
+	var oldText, newText, crlfText strings.Builder
+	for i := range lines {
+		fmt.Fprintf(&oldText, "line %d\n", i)
+		fmt.Fprintf(&newText, "LINE %d\n", i)
+		fmt.Fprintf(&crlfText, "line %d\r\n", i)
+	}
+
+	for _, rewritten := range []string{newText.String(), crlfText.String()} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		diff, added, removed := unifiedDiff("f", oldText.String(), rewritten)
+		runtime.ReadMemStats(&after)
+
+		if added != lines || removed != lines {
+			t.Errorf("unifiedDiff() added %d and removed %d lines, want %d each", added, removed, lines)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+			t.Errorf("unifiedDiff() allocated %d MB", allocated>>20)
+		}
+
+		// Patches are read with LF line endings, so only the first rewrite round-trips
+		if !strings.Contains(rewritten, "\r") {
+			got, err := applyPatchText(oldText.String(), diff)
+			if err != nil {
+				t.Fatalf("applyPatchText() error = %v", err)
+			}
+			if got != rewritten {
+				t.Errorf("applying the diff doesn't give the new text")
+			}
+		}
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	patch := "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n@@ -10,2 +10,3 @@\n x\n\n+y\n\\ No newline at end of file\n"
+
+	parsed, err := parsePatch(patch)
+	if err != nil {
+		t.Fatalf("parsePatch() error = %v", err)
+	}
+	if parsed.path != "b/f" {
+		t.Errorf("parsePatch() path = %q, want %q", parsed.path, "b/f")
+	}
+	hunks := parsed.hunks
+	if len(hunks) != 2 || hunks[0].oldStart != 1 || hunks[1].oldStart != 10 {
+		t.Fatalf("parsePatch() = %+v, want hunks at lines 1 and 10", hunks)
+	}
+
+	var kinds string
+	for _, line := range hunks[1].lines {
+		kinds += string(line.kind)
+	}
+	// The empty line is a context line whose leading space was stripped
+	if kinds != "  +" {
+		t.Errorf("second hunk line kinds = %q, want %q", kinds, "  +")
+	}
+	if last := hunks[1].lines[2]; last.text != "y" || !last.noNewline {
+		t.Errorf("last line = %+v, want %q without newline", last, "y")
+	}
+
+	invalid := map[string]string{
+		"empty":            "",
+		"no hunks":         "just text\n",
+		"unknown line":     "@@ -1 +1 @@\n a\n*b\n",
+		"hunk ends early":  "@@ -1,3 +1,3 @@\n a\n-b\n+B\n",
+		"too many lines":   "@@ -1,1 +1,1 @@\n-a\n+A\n-b\n",
+		"second file":      "--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+A\ndiff --git a/y b/y\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-b\n+B\n",
+		"text after hunks": "@@ -1 +1 @@\n-a\n+A\ntrailing text\n",
+	}
+	for name, patch := range invalid {
+		if _, err := parsePatch(patch); err == nil {
+			t.Errorf("%s: parsePatch(%q) succeeded, want an error", name, patch)
+		}
+	}
+}
+
+func TestParsePatchHeaderLikeLines(t *testing.T) {
+	// Removing "-- comment" and adding "++ comment" gives lines that look
+	// like file headers
+	patch := "--- a/f.sql\n+++ b/f.sql\n@@ -1,3 +1,3 @@\n x\n--- comment\n+++ comment\n y\n"
+
+	got, err := applyPatchText("x\n-- comment\ny\n", patch)
+	if err != nil {
+		t.Fatalf("applyPatchText() error = %v", err)
+	}
+	if want := "x\n++ comment\ny\n"; got != want {
+		t.Errorf("applyPatchText() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "at the stated line",
+			text:  "a\nb\nc\n",
+			patch: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  "a\nB\nc\n",
+		},
+		{
+			name:  "moved by lines added above",
+			text:  "new\nnew\na\nb\nc\n",
+			patch: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:  "new\nnew\na\nB\nc\n",
+		},
+		{
+			name:  "later hunk follows the shift of an earlier one",
+			text:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			patch: "@@ -1,2 +1,4 @@\n 1\n+1a\n+1b\n 2\n@@ -8,2 +10,2 @@\n 8\n-9\n+nine\n",
+			want:  "1\n1a\n1b\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+		},
+		{
+			name:  "insert only",
+			text:  "a\nb\n",
+			patch: "@@ -1,0 +2,1 @@\n+x\n",
+			want:  "a\nx\nb\n",
+		},
+		{
+			name:  "insert into empty file",
+			text:  "",
+			patch: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:  "a\nb\n",
+		},
+		{
+			name:  "keeps CRLF line endings",
+			text:  "a\r\nb\r\n",
+			patch: "@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			want:  "a\r\nB\r\n",
+		},
+		{
+			name:  "no newline at end",
+			text:  "a\nb\n",
+			patch: "@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+			want:  "a\nb",
+		},
+		{
+			name:    "context doesn't match",
+			text:    "a\nb\nc\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n-x\n+y\n",
+			wantErr: true,
+		},
+		{
+			name:    "ambiguous context away from the stated line",
+			text:    "a\nb\nz\na\nb\n",
+			patch:   "@@ -3,2 +3,2 @@\n a\n-b\n+B\n",
+			wantErr: true,
+		},
+		{
+			name:  "repeated context at the stated line",
+			text:  "a\nb\nz\na\nb\n",
+			patch: "@@ -4,2 +4,2 @@\n a\n-b\n+B\n",
+			want:  "a\nb\nz\na\nB\n",
+		},
+		{
+			name:    "insert beyond the end",
+			text:    "a\n",
+			patch:   "@@ -5,0 +6,1 @@\n+x\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyPatchText(tt.text, tt.patch)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("applyPatchText() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPatchText() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("applyPatchText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func applyPatchText(text string, patch string) (string, error) {
+	parsed, err := parsePatch(patch)
+	if err != nil {
+		return "", err
+	}
+	return applyPatch(text, parsed.hunks)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxEditBytes caps the size of the files edit_file reads and diffs
+const maxEditBytes = 4 << 20
+
+type TextEdit struct {
+	OldText    string `json:"old_text" jsonschema:"Exact text to replace, including whitespace; must occur exactly once unless replace_all is set"`
+	NewText    string `json:"new_text" jsonschema:"Text to put in its place"`
+	ReplaceAll bool   `json:"replace_all,omitempty" jsonschema:"Replace every occurrence of old_text"`
+}
+
+type EditFileInput struct {
+	Path           string     `json:"path" jsonschema:"Path of the file to edit, relative to the root"`
+	Edits          []TextEdit `json:"edits,omitempty" jsonschema:"Search/replace edits, applied in order"`
+	Patch          string     `json:"patch,omitempty" jsonschema:"Unified diff of this one file to apply instead of edits"`
+	DryRun         bool       `json:"dry_run,omitempty" jsonschema:"Only return the diff, don't change the file"`
+	ExpectedSHA256 string     `json:"expected_sha256,omitempty" jsonschema:"Only edit if the file's current SHA-256 is this one"`
+}
+
+type EditFileOutput struct {
+	Path         string `json:"path" jsonschema:"Path of the edited file"`
+	Applied      bool   `json:"applied" jsonschema:"Whether the file was changed; false for a dry run or when nothing changed"`
+	Diff         string `json:"diff" jsonschema:"Unified diff of the change"`
+	LinesAdded   int    `json:"lines_added" jsonschema:"Number of lines added"`
+	LinesRemoved int    `json:"lines_removed" jsonschema:"Number of lines removed"`
+	SHA256       string `json:"sha256,omitempty" jsonschema:"SHA-256 of the file after the edit"`
+}
+
+func (ft *FileTools) EditFile(ctx context.Context, req *mcp.CallToolRequest, input EditFileInput) (
+	*mcp.CallToolResult,
+	EditFileOutput,
+	error,
+) {
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+	}
+
+	if (len(input.Edits) == 0) == (input.Patch == "") {
+		return errorResult("Failed to edit file: give either edits or a patch"), EditFileOutput{}, nil
+	}
+
+	filePath, err := sandbox.Resolve(input.Path)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+	}
+
+	var patch *filePatch
+	if input.Patch != "" {
+		if patch, err = parsePatch(input.Patch); err != nil {
+			return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+		}
+		if patch.path != "" && !patchNamesFile(sandbox, patch.path, filePath) {
+			return errorResult(fmt.Sprintf("Failed to edit file: the patch is for %s, not %s", patch.path, input.Path)), EditFileOutput{}, nil
+		}
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+	}
+	if info.Size() > maxEditBytes {
+		return errorResult(fmt.Sprintf("Failed to edit file: %s is %d bytes, the limit is %d", input.Path, info.Size(), maxEditBytes)), EditFileOutput{}, nil
+	}
+
+	if input.ExpectedSHA256 != "" {
+		if err := checkFileHash(filePath, input.ExpectedSHA256); err != nil {
+			return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+		}
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+	}
+	if isBinary(content) {
+		return errorResult(fmt.Sprintf("Failed to edit file: %s is a binary file", input.Path)), EditFileOutput{}, nil
+	}
+	oldText := string(content)
+
+	var newText string
+	if patch != nil {
+		newText, err = applyPatch(oldText, patch.hunks)
+	} else {
+		newText, err = applyEdits(oldText, input.Edits)
+	}
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+	}
+
+	output := EditFileOutput{Path: sandbox.DisplayPath(filePath)}
+	output.Diff, output.LinesAdded, output.LinesRemoved = unifiedDiff(output.Path, oldText, newText)
+	if input.DryRun || newText == oldText {
+		return nil, output, nil
+	}
+
+	if err := writeFileAtomic(filePath, strings.NewReader(newText), false); err != nil {
+		return errorResult(fmt.Sprintf("Failed to edit file: %v", err)), EditFileOutput{}, nil
+	}
+	output.Applied = true
+
+	if output.SHA256, err = fileSHA256(filePath); err != nil {
+		return errorResult(fmt.Sprintf("File edited, but reading it back failed: %v", err)), EditFileOutput{}, nil
+	}
+
+	return nil, output, nil
+}
+
+// applyEdits applies search/replace edits in order. An edit fails if its
+// old text is missing, or found more than once without replace_all.
+func applyEdits(text string, edits []TextEdit) (string, error) {
+	for i, edit := range edits {
+		if edit.OldText == "" {
+			return "", fmt.Errorf("edit %d: old_text is empty", i+1)
+		}
+
+		switch count := strings.Count(text, edit.OldText); {
+		case count == 0:
+			return "", fmt.Errorf("edit %d: old_text not found", i+1)
+		case count > 1 && !edit.ReplaceAll:
+			return "", fmt.Errorf("edit %d: old_text found %d times; include more surrounding text or set replace_all", i+1, count)
+		}
+
+		text = strings.ReplaceAll(text, edit.OldText, edit.NewText)
+	}
+
+	return text, nil
+}
+
+// patchNamesFile reports whether the file name in a patch header is the
+// file being edited. The a/ and b/ prefixes of git diffs are optional.
+func patchNamesFile(sandbox *Sandbox, name string, filePath string) bool {
+	candidates := []string{name}
+	if rest, ok := strings.CutPrefix(name, "a/"); ok {
+		candidates = append(candidates, rest)
+	} else if rest, ok := strings.CutPrefix(name, "b/"); ok {
+		candidates = append(candidates, rest)
+	}
+
+	for _, candidate := range candidates {
+		if realPath, err := sandbox.Resolve(candidate); err == nil && realPath == filePath {
+			return true
+		}
+	}
+	return false
+}
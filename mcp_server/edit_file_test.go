@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestEditFilePatchHeader(t *testing.T) {
+	sandbox, _, _ := newTestSandbox(t)
+	ft := NewFileTools(sandbox)
+	hunk := "@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+y\n\\ No newline at end of file\n"
+
+	tests := []struct {
+		name    string
+		headers string
+		wantErr string
+	}{
+		{name: "no headers"},
+		{name: "git prefixes", headers: "--- a/a.txt\n+++ b/a.txt\n"},
+		{name: "plain name with timestamp", headers: "--- a.txt\t2024-01-01\n+++ a.txt\t2024-01-02\n"},
+		{name: "other file", headers: "--- a/sub/b.txt\n+++ b/sub/b.txt\n", wantErr: "the patch is for b/sub/b.txt"},
+		{name: "two files", headers: "--- a/a.txt\n+++ b/a.txt\n" + hunk + "--- a/sub/b.txt\n+++ b/sub/b.txt\n", wantErr: "more than one file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, output, _ := ft.EditFile(context.Background(), nil, EditFileInput{Path: "a.txt", Patch: tt.headers + hunk, DryRun: true})
+
+			if tt.wantErr != "" {
+				if result == nil || !result.IsError || !strings.Contains(resultText(result), tt.wantErr) {
+					t.Errorf("EditFile() = %v, want an error containing %q", resultText(result), tt.wantErr)
+				}
+				return
+			}
+			if result != nil {
+				t.Fatalf("EditFile() error = %s", resultText(result))
+			}
+			if output.LinesAdded != 1 || output.LinesRemoved != 1 {
+				t.Errorf("EditFile() diff = %q", output.Diff)
+			}
+		})
+	}
+}
+
+func TestEditFileSizeLimit(t *testing.T) {
+	sandbox, root, _ := newTestSandbox(t)
+	large := strings.Repeat("line\n", maxEditBytes/5+1)
+	if err := os.WriteFile(filepath.Join(root, "large.txt"), []byte(large), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, _, _ := NewFileTools(sandbox).EditFile(context.Background(), nil, EditFileInput{
+		Path:  "large.txt",
+		Edits: []TextEdit{{OldText: "line", NewText: "LINE", ReplaceAll: true}},
+	})
+	if result == nil || !result.IsError || !strings.Contains(resultText(result), "the limit is") {
+		t.Fatalf("EditFile() = %q, want a size limit error", resultText(result))
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "large.txt")); string(content) != large {
+		t.Errorf("large.txt was changed")
+	}
+}
+
+// resultText returns the text content of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	if result == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, content := range result.Content {
+		if c, ok := content.(*mcp.TextContent); ok {
+			text.WriteString(c.Text)
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// FileTools implements the filesystem tools. Every path is resolved through
+// the sandbox of the calling session, so the tools never touch anything
+// outside the allowed roots.
+//
+// Lists in the results start out empty rather than nil, also when a tool
+// fails: a nil slice is encoded as null, which the output schemas reject.
+type FileTools struct {
+	sandbox  *Sandbox // Server-wide roots
+	sessions sessionSandboxes
+}
+
+func NewFileTools(sandbox *Sandbox) *FileTools {
+	return &FileTools{sandbox: sandbox}
+}
+
+// defaultSearchMaxResults caps the files returned when the caller sets no limit
+const defaultSearchMaxResults = 500
+
+type SearchFilesInput struct {
+	Pattern    string   `json:"pattern" jsonschema:"File pattern to search for (e.g. *.md, docs/**/*.md); a pattern without / matches file names at any depth"`
+	Exclude    []string `json:"exclude,omitempty" jsonschema:"Skip files and directories matching one of these globs"`
+	MaxDepth   int      `json:"max_depth,omitempty" jsonschema:"Directory levels to descend into, zero means no limit"`
+	MaxResults int      `json:"max_results,omitempty" jsonschema:"Maximum number of files to return (default 500)"`
+	NoIgnore   bool     `json:"no_ignore,omitempty" jsonschema:"Also search .git, node_modules and files ignored by .gitignore"`
+}
+
+type SearchFilesOutput struct {
+	Files     []string `json:"files" jsonschema:"List of found file paths, relative to the root"`
+	Truncated bool     `json:"truncated" jsonschema:"Whether more files were found than returned"`
+}
+
+// Default limits for read_files, so large files can't flood the model's context
+const (
+	defaultReadMaxBytesPerFile = 64 * 1024
+	defaultReadMaxTotalBytes   = 256 * 1024
+)
+
+type ReadFileRequest struct {
+	Path      string `json:"path" jsonschema:"Path of the file to read"`
+	StartLine int    `json:"start_line,omitempty" jsonschema:"First line to read, starting at 1"`
+	EndLine   int    `json:"end_line,omitempty" jsonschema:"Last line to read, zero means up to the end"`
+	Offset    int64  `json:"offset,omitempty" jsonschema:"Byte offset to start reading at; can't be combined with a line range"`
+	Length    int64  `json:"length,omitempty" jsonschema:"Number of bytes to read from the offset, zero means up to the end"`
+}
+
+type ReadFilesInput struct {
+	Files           []ReadFileRequest `json:"files" jsonschema:"Files to read, each with an optional line or byte range"`
+	MaxBytesPerFile int               `json:"max_bytes_per_file,omitempty" jsonschema:"Maximum bytes of content returned per file (default 65536)"`
+	MaxTotalBytes   int               `json:"max_total_bytes,omitempty" jsonschema:"Maximum bytes of content returned for all files together (default 262144)"`
+}
+
+type ReadFileResult struct {
+	Path      string `json:"path" jsonschema:"Path of the file as requested"`
+	Size      int64  `json:"size" jsonschema:"File size in bytes"`
+	SHA256    string `json:"sha256,omitempty" jsonschema:"SHA-256 of the whole file, for save_to_file's expected_sha256; left out for files over 16 MB"`
+	Encoding  string `json:"encoding,omitempty" jsonschema:"Detected encoding: utf-8, utf-16le, utf-16be or binary; binary files have no content"`
+	Content   string `json:"content,omitempty" jsonschema:"Text of the selected range, ending with a marker if truncated"`
+	Truncated bool   `json:"truncated" jsonschema:"Whether the content was cut short by a size limit"`
+	Error     string `json:"error,omitempty" jsonschema:"Why the file couldn't be read"`
+}
+
+type ReadFilesOutput struct {
+	Files []ReadFileResult `json:"files" jsonschema:"One result per requested file"`
+}
+
+type SaveToFileInput struct {
+	Filename       string `json:"filename" jsonschema:"Path of the file to save, relative to the root; missing parent directories are created"`
+	Text           string `json:"text" jsonschema:"Text content to save to the file (at most 1 MiB)"`
+	Mode           string `json:"mode,omitempty" jsonschema:"create (fail if the file exists), overwrite (the default) or append"`
+	ExpectedSHA256 string `json:"expected_sha256,omitempty" jsonschema:"Only write if the file's current SHA-256 is this one, to avoid overwriting changes made since it was read"`
+}
+
+type SaveToFileOutput struct {
+	FilePath string `json:"file_path" jsonschema:"Full path to the saved file"`
+	Success  bool   `json:"success" jsonschema:"Whether the save was successful"`
+	SHA256   string `json:"sha256,omitempty" jsonschema:"SHA-256 of the file after the write, for use as expected_sha256 next time"`
+}
+
+func (ft *FileTools) SearchFiles(ctx context.Context, req *mcp.CallToolRequest, input SearchFilesInput) (
+	*mcp.CallToolResult,
+	SearchFilesOutput,
+	error,
+) {
+	output := SearchFilesOutput{Files: []string{}}
+
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to search files: %v", err)), output, nil
+	}
+
+	for _, glob := range append([]string{input.Pattern}, input.Exclude...) {
+		if err := validateGlob(glob); err != nil {
+			return errorResult(fmt.Sprintf("Failed to search files: %v", err)), output, nil
+		}
+	}
+
+	maxResults := input.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultSearchMaxResults
+	}
+	opts := walkOptions{Exclude: input.Exclude, MaxDepth: input.MaxDepth, NoIgnore: input.NoIgnore}
+
+	for _, root := range sandbox.Roots() {
+		err := walkFiles(ctx, sandbox, root, opts, func(path string, rel string) error {
+			if !matchesAnyGlob([]string{input.Pattern}, rel) {
+				return nil
+			}
+			// Skip symlinks that point outside the roots
+			if _, err := sandbox.Resolve(path); err != nil {
+				return nil
+			}
+			if len(output.Files) >= maxResults {
+				output.Truncated = true
+				return errStopWalk
+			}
+			output.Files = append(output.Files, sandbox.DisplayPath(path))
+			return nil
+		})
+
+		if errors.Is(err, errStopWalk) {
+			break
+		}
+		if err != nil {
+			return errorResult(fmt.Sprintf("Failed to search files: %v", err)), SearchFilesOutput{Files: []string{}}, nil
+		}
+	}
+
+	return nil, output, nil
+}
+
+func (ft *FileTools) ReadFiles(ctx context.Context, req *mcp.CallToolRequest, input ReadFilesInput) (
+	*mcp.CallToolResult,
+	ReadFilesOutput,
+	error,
+) {
+	output := ReadFilesOutput{Files: []ReadFileResult{}}
+
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to read files: %v", err)), output, nil
+	}
+
+	maxPerFile := input.MaxBytesPerFile
+	if maxPerFile <= 0 {
+		maxPerFile = defaultReadMaxBytesPerFile
+	}
+	maxTotal := input.MaxTotalBytes
+	if maxTotal <= 0 {
+		maxTotal = defaultReadMaxTotalBytes
+	}
+	remaining := maxTotal
+
+	// A file that can't be read gets an error in its result, the others are still read
+	for _, file := range input.Files {
+		filePath := strings.TrimSpace(file.Path)
+		if filePath == "" {
+			continue
+		}
+
+		rng := readRange{startLine: file.StartLine, endLine: file.EndLine, offset: file.Offset, length: file.Length}
+		var result ReadFileResult
+		if err := rng.validate(); err != nil {
+			result.Error = err.Error()
+		} else if remaining <= 0 {
+			result.Error = fmt.Sprintf("not read, the total limit of %d bytes is used up", maxTotal)
+		} else if realPath, err := sandbox.Resolve(filePath); err != nil {
+			result.Error = err.Error()
+		} else {
+			result = readFile(realPath, rng, min(maxPerFile, remaining))
+			remaining -= len(result.Content)
+		}
+
+		result.Path = filePath
+		output.Files = append(output.Files, result)
+	}
+
+	return nil, output, nil
+}
+
+func (ft *FileTools) SaveToFile(ctx context.Context, req *mcp.CallToolRequest, input SaveToFileInput) (
+	*mcp.CallToolResult,
+	SaveToFileOutput,
+	error,
+) {
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to save file: %v", err)), SaveToFileOutput{Success: false}, nil
+	}
+
+	mode := input.Mode
+	if mode == "" {
+		mode = writeModeOverwrite
+	}
+	if mode != writeModeCreate && mode != writeModeOverwrite && mode != writeModeAppend {
+		return errorResult(fmt.Sprintf("Failed to save file: unknown mode %q", input.Mode)), SaveToFileOutput{Success: false}, nil
+	}
+	if mode == writeModeCreate && input.ExpectedSHA256 != "" {
+		return errorResult("Failed to save file: expected_sha256 can't be used with mode create"), SaveToFileOutput{Success: false}, nil
+	}
+	if len(input.Text) > maxWriteBytes {
+		return errorResult(fmt.Sprintf("Failed to save file: content is %d bytes, the limit is %d", len(input.Text), maxWriteBytes)), SaveToFileOutput{Success: false}, nil
+	}
+
+	// Resolve the file path inside the root directory
+	filePath, err := sandbox.Resolve(input.Filename)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to save file: %v", err)), SaveToFileOutput{Success: false}, nil
+	}
+
+	if input.ExpectedSHA256 != "" {
+		if err := checkFileHash(filePath, input.ExpectedSHA256); err != nil {
+			return errorResult(fmt.Sprintf("Failed to save file: %v", err)), SaveToFileOutput{Success: false}, nil
+		}
+	}
+
+	// The resolved path is inside the root, so are the directories created for it
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return errorResult(fmt.Sprintf("Failed to save file: %v", err)), SaveToFileOutput{Success: false}, nil
+	}
+
+	// Write content to file
+	if mode == writeModeAppend {
+		err = appendFile(filePath, []byte(input.Text))
+	} else {
+		err = writeFileAtomic(filePath, strings.NewReader(input.Text), mode == writeModeCreate)
+	}
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to save file: %v", err)), SaveToFileOutput{Success: false}, nil
+	}
+
+	hash, err := fileSHA256(filePath)
+	if err != nil {
+		return errorResult(fmt.Sprintf("File saved, but reading it back failed: %v", err)), SaveToFileOutput{Success: false}, nil
+	}
+
+	return nil, SaveToFileOutput{
+		FilePath: filePath,
+		Success:  true,
+		SHA256:   hash,
+	}, nil
+}
+
+// errorResult reports a failure to the model as a tool error
+func errorResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: text},
+		},
+		IsError: true,
+	}
+}
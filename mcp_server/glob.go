@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether a slash-separated relative path matches the
+// pattern. Each path segment is matched with path.Match, and a "**" segment
+// stands for any number of directories, including none.
+func matchGlob(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range len(name) + 1 {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// validateGlob reports a malformed pattern before any files are walked
+func validateGlob(pattern string) error {
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesAnyGlob reports whether a slash-separated path relative to its root
+// matches one of the globs. Globs without a slash are matched against the
+// base name only, so "*.md" finds Markdown files at any depth.
+func matchesAnyGlob(globs []string, rel string) bool {
+	base := path.Base(rel)
+
+	for _, glob := range globs {
+		glob = strings.TrimPrefix(glob, "/")
+		if !strings.Contains(glob, "/") {
+			if matchGlob(glob, base) {
+				return true
+			}
+		} else if matchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
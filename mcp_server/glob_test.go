@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.md", "README.md", true},
+		{"*.md", "docs/README.md", false},
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"docs/**/*.md", "docs/a.md", true},
+		{"docs/**/*.md", "docs/sub/deep/a.md", true},
+		{"docs/**/*.md", "other/a.md", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/main.go", true},
+		{"**", "a/b/c", true},
+		{"a/**", "a/b/c", true},
+		{"a/**", "b/c", false},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/c", false},
+		{"file?.txt", "file1.txt", true},
+		{"file[0-9].txt", "filex.txt", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesAnyGlob(t *testing.T) {
+	tests := []struct {
+		globs []string
+		rel   string
+		want  bool
+	}{
+		{[]string{"*.md"}, "docs/sub/a.md", true},
+		{[]string{"/docs/*.md"}, "docs/a.md", true},
+		{[]string{"build", "*.log"}, "out/build", true},
+		{[]string{"build", "*.log"}, "out/app.log", true},
+		{[]string{"build", "*.log"}, "out/app.txt", false},
+		{nil, "a.txt", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesAnyGlob(tt.globs, tt.rel); got != tt.want {
+			t.Errorf("matchesAnyGlob(%q, %q) = %v, want %v", tt.globs, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	for _, pattern := range []string{"*.md", "docs/**/*.md", "[a-z]*"} {
+		if err := validateGlob(pattern); err != nil {
+			t.Errorf("validateGlob(%q) error = %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"[", "docs/[a-/*.md"} {
+		if err := validateGlob(pattern); err == nil {
+			t.Errorf("validateGlob(%q) succeeded, want an error", pattern)
+		}
+	}
+}
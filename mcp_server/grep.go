@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultGrepMaxResults caps the matches returned when the caller sets no limit
+const defaultGrepMaxResults = 100
+
+// Line limits of grep_files
+const (
+	maxGrepLineBytes  = 1 << 20 // Longer lines end the search of their file
+	maxGrepTextLength = 1000    // Returned lines are cut to this many bytes
+)
+
+// binarySniffLength is how much of a file is checked for NUL bytes to tell
+// binary files from text
+const binarySniffLength = 8000
+
+type GrepFilesInput struct {
+	Pattern      string   `json:"pattern" jsonschema:"Regular expression (RE2 syntax) to search file contents for"`
+	Path         string   `json:"path,omitempty" jsonschema:"Directory or file to search, relative to the root; all roots when empty"`
+	Include      []string `json:"include,omitempty" jsonschema:"Only search files matching one of these globs (e.g. *.go); a glob containing / is matched against the path relative to the root"`
+	Exclude      []string `json:"exclude,omitempty" jsonschema:"Skip files and directories matching one of these globs"`
+	NoIgnore     bool     `json:"no_ignore,omitempty" jsonschema:"Also search .git, node_modules and files ignored by .gitignore"`
+	IgnoreCase   bool     `json:"ignore_case,omitempty" jsonschema:"Match case-insensitively"`
+	ContextLines int      `json:"context_lines,omitempty" jsonschema:"Number of lines to include before and after each match"`
+	MaxResults   int      `json:"max_results,omitempty" jsonschema:"Maximum number of matches to return (default 100)"`
+}
+
+type GrepMatch struct {
+	Path   string   `json:"path" jsonschema:"Path of the file, relative to the root"`
+	Line   int      `json:"line" jsonschema:"Line number, starting at 1"`
+	Text   string   `json:"text" jsonschema:"The matching line, cut short if very long"`
+	Before []string `json:"before,omitempty" jsonschema:"Context lines before the match"`
+	After  []string `json:"after,omitempty" jsonschema:"Context lines after the match"`
+}
+
+type GrepFilesOutput struct {
+	Matches    []GrepMatch `json:"matches" jsonschema:"Matching lines"`
+	Truncated  bool        `json:"truncated" jsonschema:"Whether more matches were found than returned"`
+	Incomplete []string    `json:"incomplete,omitempty" jsonschema:"Files that were only searched up to some line, with the reason"`
+}
+
+func (ft *FileTools) GrepFiles(ctx context.Context, req *mcp.CallToolRequest, input GrepFilesInput) (
+	*mcp.CallToolResult,
+	GrepFilesOutput,
+	error,
+) {
+	output := GrepFilesOutput{Matches: []GrepMatch{}}
+
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to search file contents: %v", err)), output, nil
+	}
+
+	expr := input.Pattern
+	if input.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Invalid pattern: %v", err)), output, nil
+	}
+	for _, glob := range append(append([]string{}, input.Include...), input.Exclude...) {
+		if err := validateGlob(glob); err != nil {
+			return errorResult(fmt.Sprintf("Failed to search file contents: %v", err)), output, nil
+		}
+	}
+
+	maxResults := input.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultGrepMaxResults
+	}
+
+	searchRoots := sandbox.Roots()
+	if strings.TrimSpace(input.Path) != "" {
+		realPath, err := sandbox.Resolve(input.Path)
+		if err != nil {
+			return errorResult(fmt.Sprintf("Failed to search file contents: %v", err)), output, nil
+		}
+		searchRoots = []string{realPath}
+	}
+
+	g := &grepper{
+		sandbox:    sandbox,
+		re:         re,
+		include:    input.Include,
+		context:    max(input.ContextLines, 0),
+		maxResults: maxResults,
+		output:     &output,
+	}
+	opts := walkOptions{Exclude: input.Exclude, NoIgnore: input.NoIgnore}
+
+	for _, root := range searchRoots {
+		err := walkFiles(ctx, sandbox, root, opts, g.visit)
+		if errors.Is(err, errStopWalk) {
+			break
+		}
+		if err != nil {
+			return errorResult(fmt.Sprintf("Failed to search file contents: %v", err)), GrepFilesOutput{Matches: []GrepMatch{}}, nil
+		}
+	}
+
+	return nil, output, nil
+}
+
+// grepper holds the state of one grep_files call
+type grepper struct {
+	sandbox    *Sandbox
+	re         *regexp.Regexp
+	include    []string
+	context    int
+	maxResults int
+	output     *GrepFilesOutput
+}
+
+func (g *grepper) visit(path string, rel string) error {
+	if len(g.include) > 0 && !matchesAnyGlob(g.include, rel) {
+		return nil
+	}
+
+	// Skip symlinks that point outside the roots, and anything that isn't a
+	// regular file once links are followed
+	realPath, err := g.sandbox.Resolve(path)
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(realPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	file, err := os.Open(realPath)
+	if err != nil {
+		// Unreadable files are skipped, like binary ones
+		return nil
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	if sniff, _ := reader.Peek(binarySniffLength); isBinary(sniff) {
+		return nil
+	}
+
+	return g.grepReader(g.sandbox.DisplayPath(path), reader)
+}
+
+// grepReader searches the text line by line, so only the current line and
+// the context lines are held in memory
+func (g *grepper) grepReader(path string, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxGrepLineBytes)
+
+	var before []string // Up to g.context lines preceding the current one
+	var open []int      // Matches still collecting lines after them
+	stopping := false   // The limit is reached; only the open matches are finished
+
+	lineNumber := 1
+	for ; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		text := shortenLine(line)
+
+		for i := 0; i < len(open); {
+			match := &g.output.Matches[open[i]]
+			match.After = append(match.After, text)
+			if len(match.After) == g.context {
+				open = append(open[:i], open[i+1:]...)
+			} else {
+				i++
+			}
+		}
+
+		if !stopping && g.re.MatchString(line) {
+			if len(g.output.Matches) >= g.maxResults {
+				g.output.Truncated = true
+				stopping = true
+			} else {
+				match := GrepMatch{Path: path, Line: lineNumber, Text: text}
+				if len(before) > 0 {
+					match.Before = append([]string(nil), before...)
+				}
+				g.output.Matches = append(g.output.Matches, match)
+				if g.context > 0 {
+					open = append(open, len(g.output.Matches)-1)
+				}
+			}
+		}
+
+		if stopping && len(open) == 0 {
+			return errStopWalk
+		}
+
+		if g.context > 0 {
+			if len(before) == g.context {
+				before = before[1:]
+			}
+			before = append(before, text)
+		}
+	}
+
+	// A file with a line too long to scan, or that fails to read, is searched
+	// only up to that point and listed as incomplete
+	if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+		g.output.Incomplete = append(g.output.Incomplete,
+			fmt.Sprintf("%s: line %d is longer than %d bytes, the rest of the file was not searched", path, lineNumber, maxGrepLineBytes))
+	} else if err != nil {
+		g.output.Incomplete = append(g.output.Incomplete,
+			fmt.Sprintf("%s: reading stopped at line %d: %v", path, lineNumber, err))
+	}
+
+	if stopping {
+		return errStopWalk
+	}
+	return nil
+}
+
+// shortenLine cuts a line returned to the model to maxGrepTextLength bytes
+func shortenLine(line string) string {
+	if len(line) <= maxGrepTextLength {
+		return line
+	}
+	return string(trimIncompleteRune([]byte(line[:maxGrepTextLength]))) + " [...]"
+}
+
+// isBinary reports whether the content looks like a binary file
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLength)], 0) >= 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func grepText(t *testing.T, pattern string, context int, maxResults int, text string) GrepFilesOutput {
+	t.Helper()
+
+	output := GrepFilesOutput{Matches: []GrepMatch{}}
+	g := &grepper{re: regexp.MustCompile(pattern), context: context, maxResults: maxResults, output: &output}
+	if err := g.grepReader("f.txt", strings.NewReader(text)); err != nil && err != errStopWalk {
+		t.Fatalf("grepReader() error = %v", err)
+	}
+	return output
+}
+
+func TestGrepReaderContext(t *testing.T) {
+	output := grepText(t, "match", 2, 10, "1\n2\nmatch a\n4\nmatch b\r\n6\n7\n8\n")
+
+	want := []GrepMatch{
+		{Path: "f.txt", Line: 3, Text: "match a", Before: []string{"1", "2"}, After: []string{"4", "match b"}},
+		{Path: "f.txt", Line: 5, Text: "match b", Before: []string{"match a", "4"}, After: []string{"6", "7"}},
+	}
+	if len(output.Matches) != len(want) || output.Truncated {
+		t.Fatalf("grepReader() = %+v, want %d matches", output, len(want))
+	}
+	for i, w := range want {
+		got := output.Matches[i]
+		if got.Line != w.Line || got.Text != w.Text || strings.Join(got.Before, "|") != strings.Join(w.Before, "|") ||
+			strings.Join(got.After, "|") != strings.Join(w.After, "|") {
+			t.Errorf("match %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestGrepReaderLimits(t *testing.T) {
+	t.Run("max results", func(t *testing.T) {
+		output := grepText(t, "x", 1, 2, "x1\nx2\nx3\nx4\n")
+		if len(output.Matches) != 2 || !output.Truncated {
+			t.Fatalf("grepReader() = %+v, want 2 matches and truncated", output)
+		}
+		// The last returned match still gets its context
+		if after := output.Matches[1].After; len(after) != 1 || after[0] != "x3" {
+			t.Errorf("context after the last match = %q", after)
+		}
+	})
+
+	t.Run("long line", func(t *testing.T) {
+		line := "match " + strings.Repeat("é", maxGrepTextLength)
+		output := grepText(t, "match", 0, 10, line+"\n")
+		if len(output.Matches) != 1 {
+			t.Fatalf("grepReader() = %+v, want 1 match", output)
+		}
+		text := output.Matches[0].Text
+		if len(text) > maxGrepTextLength+len(" [...]") || !strings.HasSuffix(text, " [...]") || !strings.HasPrefix(text, "match é") {
+			t.Errorf("long line returned as %d bytes: %q...", len(text), text[:20])
+		}
+	})
+
+	t.Run("line too long to scan", func(t *testing.T) {
+		text := "match 1\n" + strings.Repeat("a", maxGrepLineBytes+1) + "\nmatch 2\n"
+		output := grepText(t, "match", 0, 10, text)
+		if len(output.Matches) != 1 || output.Matches[0].Line != 1 {
+			t.Errorf("grepReader() = %+v, want only the match before the long line", output)
+		}
+		if len(output.Incomplete) != 1 || !strings.Contains(output.Incomplete[0], "f.txt: line 2 is longer") {
+			t.Errorf("incomplete files = %q, want f.txt reported at line 2", output.Incomplete)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+// serveHTTP serves the server over the MCP streamable HTTP transport until ctx
+// is cancelled, then shuts down gracefully. Every client gets its own session.
+// If bearerToken is not empty, requests must carry it in the Authorization header.
+func serveHTTP(ctx context.Context, server *mcp.Server, addr string, bearerToken string) error {
+	var handler http.Handler = mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return server
+	}, nil)
+	if bearerToken != "" {
+		handler = requireBearerToken(bearerToken, handler)
+	}
+
+	httpServer := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		log.Printf("Serving MCP over streamable HTTP on %s", addr)
+		errChan <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down MCP HTTP server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Long-lived streams don't finish on their own, so close them if they
+	// outlive the timeout
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		httpServer.Close()
+	}
+
+	if err := <-errChan; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// requireBearerToken rejects requests without the expected static bearer token
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
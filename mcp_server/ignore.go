@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultIgnores are directories the file tools skip unless asked not to
+var defaultIgnores = []string{".git", ".hg", ".svn", "node_modules"}
+
+// ignoreRule is one pattern line from a .gitignore file
+type ignoreRule struct {
+	base     string // Directory of the .gitignore relative to the root, "" for the root itself
+	pattern  string
+	negate   bool // "!pattern" re-includes what an earlier rule ignored
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // Patterns with a slash are relative to base, others match at any depth
+}
+
+// ignoreRules collects the .gitignore rules seen during one walk
+type ignoreRules struct {
+	rules []ignoreRule
+}
+
+// load reads the .gitignore in dir, if any. rel is dir relative to the root.
+func (r *ignoreRules) load(dir string, rel string) {
+	content, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	if rel == "." {
+		rel = ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: rel}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		rule.anchored = strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern == "" || validateGlob(rule.pattern) != nil {
+			continue
+		}
+
+		r.rules = append(r.rules, rule)
+	}
+}
+
+// ignored reports whether the path, relative to the root, is ignored. As in
+// git, the last matching rule wins.
+func (r *ignoreRules) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range r.rules {
+		if rule.matches(rel, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (rule ignoreRule) matches(rel string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+
+	name := rel
+	if rule.base != "" {
+		if !strings.HasPrefix(rel, rule.base+"/") {
+			return false
+		}
+		name = strings.TrimPrefix(rel, rule.base+"/")
+	}
+
+	if !rule.anchored {
+		name = path.Base(name)
+	}
+	return matchGlob(rule.pattern, name)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitignores := map[string]string{
+		".gitignore":     "# build output\n*.log\n!keep.log\nbuild/\n/vendor\ndocs/*.tmp\n\\#notes\n",
+		"web/.gitignore": "dist\n/cache/\n",
+	}
+	for name, content := range gitignores {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rules ignoreRules
+	rules.load(root, ".")
+	rules.load(filepath.Join(root, "web"), "web")
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/deep/app.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"build", true, true},
+		{"sub/build", true, true},
+		{"build", false, false}, // "build/" only matches directories
+		{"vendor", true, true},
+		{"sub/vendor", true, false}, // A leading slash anchors the pattern
+		{"docs/a.tmp", false, true},
+		{"docs/sub/a.tmp", false, false},
+		{"#notes", false, true},
+		{"web/dist", true, true},
+		{"web/src/dist", false, true},
+		{"dist", true, false}, // Rules only apply below their .gitignore
+		{"web/cache", true, true},
+		{"web/src/cache", true, false},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Defaults for list_directory
+const (
+	defaultListMaxDepth   = 3
+	defaultListMaxEntries = 1000
+)
+
+// FileEntry describes a file or directory
+type FileEntry struct {
+	Name     string `json:"name" jsonschema:"Base name"`
+	Path     string `json:"path" jsonschema:"Path relative to the root"`
+	Type     string `json:"type" jsonschema:"file, directory, symlink or other"`
+	Size     int64  `json:"size" jsonschema:"Size in bytes"`
+	Modified string `json:"modified" jsonschema:"Modification time, RFC 3339"`
+	Mode     string `json:"mode" jsonschema:"Type and permission bits, e.g. -rw-r--r--"`
+	Depth    int    `json:"depth,omitempty" jsonschema:"Nesting level below the listed directory, starting at 1"`
+}
+
+type ListDirectoryInput struct {
+	Path       string `json:"path,omitempty" jsonschema:"Directory to list, relative to the root; the root when empty"`
+	Recursive  bool   `json:"recursive,omitempty" jsonschema:"Also list the contents of subdirectories"`
+	MaxDepth   int    `json:"max_depth,omitempty" jsonschema:"Levels to descend when recursive (default 3)"`
+	MaxEntries int    `json:"max_entries,omitempty" jsonschema:"Maximum number of entries to return (default 1000)"`
+	NoIgnore   bool   `json:"no_ignore,omitempty" jsonschema:"Also list .git, node_modules and files ignored by .gitignore"`
+}
+
+type ListDirectoryOutput struct {
+	Path      string      `json:"path" jsonschema:"The listed directory"`
+	Entries   []FileEntry `json:"entries" jsonschema:"Entries in tree order: each directory is followed by its contents"`
+	Truncated bool        `json:"truncated" jsonschema:"Whether more entries were found than returned"`
+}
+
+type StatFileInput struct {
+	Path string `json:"path" jsonschema:"Path of the file or directory, relative to the root"`
+}
+
+type StatFileOutput struct {
+	File FileEntry `json:"file" jsonschema:"Metadata of the file, with symlinks followed"`
+}
+
+func (ft *FileTools) ListDirectory(ctx context.Context, req *mcp.CallToolRequest, input ListDirectoryInput) (
+	*mcp.CallToolResult,
+	ListDirectoryOutput,
+	error,
+) {
+	output := ListDirectoryOutput{Entries: []FileEntry{}}
+
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to list directory: %v", err)), output, nil
+	}
+
+	path := input.Path
+	if strings.TrimSpace(path) == "" {
+		path = "."
+	}
+	dir, err := sandbox.Resolve(path)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to list directory: %v", err)), output, nil
+	}
+	output.Path = sandbox.DisplayPath(dir)
+
+	maxDepth := 1
+	if input.Recursive {
+		maxDepth = input.MaxDepth
+		if maxDepth <= 0 {
+			maxDepth = defaultListMaxDepth
+		}
+	}
+	maxEntries := input.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultListMaxEntries
+	}
+
+	l := &lister{
+		sandbox:    sandbox,
+		opts:       walkOptions{NoIgnore: input.NoIgnore},
+		maxDepth:   maxDepth,
+		maxEntries: maxEntries,
+		output:     &output,
+	}
+	if !input.NoIgnore {
+		loadParentIgnores(&l.ignore, sandbox, dir)
+		l.ignore.load(dir, filepath.ToSlash(sandbox.Rel(dir)))
+	}
+
+	err = l.list(ctx, dir, 1)
+	if errors.Is(err, errStopWalk) {
+		output.Truncated = true
+	} else if err != nil {
+		return errorResult(fmt.Sprintf("Failed to list directory: %v", err)), ListDirectoryOutput{Entries: []FileEntry{}}, nil
+	}
+
+	return nil, output, nil
+}
+
+func (ft *FileTools) StatFile(ctx context.Context, req *mcp.CallToolRequest, input StatFileInput) (
+	*mcp.CallToolResult,
+	StatFileOutput,
+	error,
+) {
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to stat file: %v", err)), StatFileOutput{}, nil
+	}
+
+	realPath, err := sandbox.Resolve(input.Path)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to stat file: %v", err)), StatFileOutput{}, nil
+	}
+
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to stat file: %v", err)), StatFileOutput{}, nil
+	}
+
+	return nil, StatFileOutput{File: newFileEntry(sandbox, realPath, info, 0)}, nil
+}
+
+// lister holds the state of one list_directory call
+type lister struct {
+	sandbox    *Sandbox
+	opts       walkOptions
+	ignore     ignoreRules
+	maxDepth   int
+	maxEntries int
+	output     *ListDirectoryOutput
+}
+
+// list adds the entries of dir, which is depth levels below the listed
+// directory, and recurses into subdirectories up to the depth limit
+func (l *lister) list(ctx context.Context, dir string, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	// An unreadable subdirectory is listed without its contents
+	if err != nil && depth == 1 {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		rel := filepath.ToSlash(l.sandbox.Rel(path))
+		isDir := entry.IsDir()
+
+		if skipPath(&l.ignore, l.opts, rel, isDir) {
+			continue
+		}
+		if len(l.output.Entries) >= l.maxEntries {
+			return errStopWalk
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+		l.output.Entries = append(l.output.Entries, newFileEntry(l.sandbox, path, info, depth))
+
+		if isDir && depth < l.maxDepth {
+			if !l.opts.NoIgnore {
+				l.ignore.load(path, rel)
+			}
+			if err := l.list(ctx, path, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func newFileEntry(sandbox *Sandbox, path string, info fs.FileInfo, depth int) FileEntry {
+	return FileEntry{
+		Name:     info.Name(),
+		Path:     sandbox.DisplayPath(path),
+		Type:     fileType(info.Mode()),
+		Size:     info.Size(),
+		Modified: info.ModTime().Format(time.RFC3339),
+		Mode:     info.Mode().String(),
+		Depth:    depth,
+	}
+}
+
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode.IsRegular():
+		return "file"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return "other"
+	}
+}
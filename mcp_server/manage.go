@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type MoveFileInput struct {
+	Source      string `json:"source" jsonschema:"Path of the file or directory to move, relative to the root"`
+	Destination string `json:"destination" jsonschema:"New path, relative to the root; missing parent directories are created"`
+	Overwrite   bool   `json:"overwrite,omitempty" jsonschema:"Replace the destination if it exists"`
+}
+
+type MoveFileOutput struct {
+	Source      string `json:"source" jsonschema:"Old path"`
+	Destination string `json:"destination" jsonschema:"New path"`
+	Success     bool   `json:"success" jsonschema:"Whether the move was successful"`
+}
+
+type CopyFileInput struct {
+	Source      string `json:"source" jsonschema:"Path of the file to copy, relative to the root"`
+	Destination string `json:"destination" jsonschema:"Path of the copy, relative to the root; missing parent directories are created"`
+	Overwrite   bool   `json:"overwrite,omitempty" jsonschema:"Replace the destination if it exists"`
+}
+
+type CopyFileOutput struct {
+	Source      string `json:"source" jsonschema:"Path of the original"`
+	Destination string `json:"destination" jsonschema:"Path of the copy"`
+	Success     bool   `json:"success" jsonschema:"Whether the copy was successful"`
+}
+
+type DeleteFileInput struct {
+	Path      string `json:"path" jsonschema:"Path of the file or directory to delete, relative to the root"`
+	Recursive bool   `json:"recursive,omitempty" jsonschema:"Delete a directory together with everything in it"`
+}
+
+type DeleteFileOutput struct {
+	Path    string `json:"path" jsonschema:"Path of the deleted file"`
+	Success bool   `json:"success" jsonschema:"Whether the delete was successful"`
+}
+
+// destructiveTool returns the annotations of a tool that can remove or
+// replace files inside the roots
+func destructiveTool(idempotent bool) *mcp.ToolAnnotations {
+	destructive, openWorld := true, false
+	return &mcp.ToolAnnotations{
+		DestructiveHint: &destructive,
+		IdempotentHint:  idempotent,
+		OpenWorldHint:   &openWorld,
+	}
+}
+
+func (ft *FileTools) MoveFile(ctx context.Context, req *mcp.CallToolRequest, input MoveFileInput) (
+	*mcp.CallToolResult,
+	MoveFileOutput,
+	error,
+) {
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+
+	// A symlink is moved itself, not the file it points to
+	source, err := sandbox.ResolveEntry(input.Source)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+	destination, err := sandbox.Resolve(input.Destination)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+	output := MoveFileOutput{Source: sandbox.DisplayPath(source), Destination: sandbox.DisplayPath(destination)}
+
+	if _, err := os.Lstat(source); err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+	if source == destination || isWithin(source, destination) {
+		return errorResult(fmt.Sprintf("Failed to move file: can't move %s into itself", output.Source)), MoveFileOutput{}, nil
+	}
+	replacing, err := checkDestination(destination, input.Overwrite)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+
+	message := fmt.Sprintf("Move %s to %s?", output.Source, output.Destination)
+	if replacing {
+		message = fmt.Sprintf("Move %s to %s, replacing the existing file?", output.Source, output.Destination)
+	}
+	if err := confirm(ctx, req, message); err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+
+	// The files may have changed while the user was deciding
+	if err := checkUnchanged(sandbox.ResolveEntry, input.Source, source); err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+	if err := checkUnchanged(sandbox.Resolve, input.Destination, destination); err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+	if nowReplacing, err := checkDestination(destination, input.Overwrite); err != nil || nowReplacing != replacing {
+		if err == nil {
+			err = fmt.Errorf("%s was created while waiting for confirmation", output.Destination)
+		}
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+	if err := moveFile(source, destination); err != nil {
+		return errorResult(fmt.Sprintf("Failed to move file: %v", err)), MoveFileOutput{}, nil
+	}
+
+	output.Success = true
+	return nil, output, nil
+}
+
+func (ft *FileTools) CopyFile(ctx context.Context, req *mcp.CallToolRequest, input CopyFileInput) (
+	*mcp.CallToolResult,
+	CopyFileOutput,
+	error,
+) {
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+	}
+
+	source, err := sandbox.Resolve(input.Source)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+	}
+	destination, err := sandbox.Resolve(input.Destination)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+	}
+	output := CopyFileOutput{Source: sandbox.DisplayPath(source), Destination: sandbox.DisplayPath(destination)}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+	}
+	if !info.Mode().IsRegular() {
+		return errorResult(fmt.Sprintf("Failed to copy file: %s is not a regular file", output.Source)), CopyFileOutput{}, nil
+	}
+	if source == destination {
+		return errorResult("Failed to copy file: source and destination are the same file"), CopyFileOutput{}, nil
+	}
+	replacing, err := checkDestination(destination, input.Overwrite)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+	}
+
+	// Copying to a new path only adds a file, replacing one needs approval
+	if replacing {
+		message := fmt.Sprintf("Copy %s to %s, replacing the existing file?", output.Source, output.Destination)
+		if err := confirm(ctx, req, message); err != nil {
+			return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+		}
+
+		// The files may have changed while the user was deciding
+		if err := checkUnchanged(sandbox.Resolve, input.Source, source); err != nil {
+			return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+		}
+		if err := checkUnchanged(sandbox.Resolve, input.Destination, destination); err != nil {
+			return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+		}
+		if _, err := checkDestination(destination, input.Overwrite); err != nil {
+			return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+	}
+	if err := copyFile(source, destination, !replacing); err != nil {
+		return errorResult(fmt.Sprintf("Failed to copy file: %v", err)), CopyFileOutput{}, nil
+	}
+
+	output.Success = true
+	return nil, output, nil
+}
+
+func (ft *FileTools) DeleteFile(ctx context.Context, req *mcp.CallToolRequest, input DeleteFileInput) (
+	*mcp.CallToolResult,
+	DeleteFileOutput,
+	error,
+) {
+	sandbox, err := ft.sandboxFor(ctx, req)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+	}
+
+	// A symlink is deleted itself, never the file it points to
+	path, err := sandbox.ResolveEntry(input.Path)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+	}
+	output := DeleteFileOutput{Path: sandbox.DisplayPath(path)}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+	}
+
+	message := fmt.Sprintf("Delete %s?", output.Path)
+	if info.IsDir() {
+		if !input.Recursive {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+			}
+			if len(entries) > 0 {
+				return errorResult(fmt.Sprintf("Failed to delete file: directory %s is not empty, set recursive to delete it with its contents", output.Path)), DeleteFileOutput{}, nil
+			}
+		}
+		if input.Recursive {
+			message = fmt.Sprintf("Delete directory %s and everything in it?", output.Path)
+		} else {
+			message = fmt.Sprintf("Delete empty directory %s?", output.Path)
+		}
+	}
+	if err := confirm(ctx, req, message); err != nil {
+		return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+	}
+
+	// The file may have been replaced while the user was deciding, e.g. a
+	// file swapped for a directory that the recursive delete would then empty
+	if err := checkUnchanged(sandbox.ResolveEntry, input.Path, path); err != nil {
+		return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+	}
+	if current, err := os.Lstat(path); err != nil || !os.SameFile(info, current) || current.Mode().Type() != info.Mode().Type() {
+		if err == nil {
+			err = fmt.Errorf("%s was replaced while waiting for confirmation", output.Path)
+		}
+		return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+	}
+
+	// RemoveAll doesn't follow symlinks, so nothing outside the directory is touched
+	if input.Recursive {
+		err = os.RemoveAll(path)
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to delete file: %v", err)), DeleteFileOutput{}, nil
+	}
+
+	output.Success = true
+	return nil, output, nil
+}
+
+// checkUnchanged resolves path again after a confirmation and fails if it no
+// longer resolves to the same place inside the roots, e.g. because a
+// directory on the way was swapped for a symlink
+func checkUnchanged(resolve func(string) (string, error), path string, resolved string) error {
+	current, err := resolve(path)
+	if err != nil {
+		return err
+	}
+	if current != resolved {
+		return fmt.Errorf("%s changed while waiting for confirmation", path)
+	}
+	return nil
+}
+
+// checkDestination reports whether an existing file would be replaced, and
+// fails if that isn't allowed. Directories are never replaced.
+func checkDestination(destination string, overwrite bool) (bool, error) {
+	info, err := os.Lstat(destination)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return false, fmt.Errorf("%s is a directory", destination)
+	}
+	if !overwrite {
+		return false, fmt.Errorf("%s already exists, set overwrite to replace it", destination)
+	}
+	return true, nil
+}
+
+// moveFile renames source to destination. Between file systems, which
+// different roots may be on, a regular file is copied and then removed.
+func moveFile(source string, destination string) error {
+	err := os.Rename(source, destination)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	info, statErr := os.Lstat(source)
+	if statErr != nil || !info.Mode().IsRegular() {
+		return err
+	}
+	if err := copyFile(source, destination, false); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
+
+// copyFile copies a regular file through a temporary file, so the
+// destination is never seen half written
+func copyFile(source string, destination string, createOnly bool) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeFileAtomic(destination, file, createOnly)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connectManageTools serves the file management tools over an in-memory
+// transport. The client accepts every confirmation after calling onConfirm.
+func connectManageTools(t *testing.T, sandbox *Sandbox, onConfirm func(message string)) *mcp.ClientSession {
+	t.Helper()
+
+	ft := NewFileTools(sandbox)
+	server := mcp.NewServer(&mcp.Implementation{Name: "test"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "move_file"}, ft.MoveFile)
+	mcp.AddTool(server, &mcp.Tool{Name: "delete_file"}, ft.DeleteFile)
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, &mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			onConfirm(req.Params.Message)
+			return &mcp.ElicitResult{Action: "accept"}, nil
+		},
+	})
+
+	client.AddRoots(&mcp.Root{URI: fileURI(sandbox.Roots()[0])})
+	return connectInMemory(t, server, client)
+}
+
+func callTool(t *testing.T, session *mcp.ClientSession, name string, args map[string]any) (string, bool) {
+	t.Helper()
+
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("CallTool(%s) error = %v", name, err)
+	}
+	var text strings.Builder
+	for _, content := range result.Content {
+		if c, ok := content.(*mcp.TextContent); ok {
+			text.WriteString(c.Text)
+		}
+	}
+	return text.String(), result.IsError
+}
+
+func TestDeleteFileConfirmation(t *testing.T) {
+	t.Run("messages", func(t *testing.T) {
+		sandbox, root, _ := newTestSandbox(t)
+		for _, dir := range []string{"empty", "full/inner"} {
+			if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var messages []string
+		session := connectManageTools(t, sandbox, func(message string) { messages = append(messages, message) })
+
+		callTool(t, session, "delete_file", map[string]any{"path": "a.txt"})
+		callTool(t, session, "delete_file", map[string]any{"path": "empty"})
+		callTool(t, session, "delete_file", map[string]any{"path": "full", "recursive": true})
+
+		want := []string{"Delete a.txt?", "Delete empty directory empty?", "Delete directory full and everything in it?"}
+		if strings.Join(messages, "|") != strings.Join(want, "|") {
+			t.Errorf("confirmations = %q, want %q", messages, want)
+		}
+		for _, name := range []string{"a.txt", "empty", "full"} {
+			if _, err := os.Lstat(filepath.Join(root, name)); !os.IsNotExist(err) {
+				t.Errorf("%s still exists", name)
+			}
+		}
+	})
+
+	t.Run("replaced while confirming", func(t *testing.T) {
+		sandbox, root, outside := newTestSandbox(t)
+		session := connectManageTools(t, sandbox, func(string) {
+			// Swap the approved directory for a symlink out of the root
+			os.RemoveAll(filepath.Join(root, "sub"))
+			os.Symlink(outside, filepath.Join(root, "sub"))
+		})
+
+		text, isError := callTool(t, session, "delete_file", map[string]any{"path": "sub/b.txt"})
+		if !isError {
+			t.Fatalf("delete_file succeeded after the path changed: %s", text)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+			t.Errorf("file outside the root was deleted: %v", err)
+		}
+	})
+
+	t.Run("file swapped for a directory", func(t *testing.T) {
+		sandbox, root, _ := newTestSandbox(t)
+		target := filepath.Join(root, "a.txt")
+		session := connectManageTools(t, sandbox, func(string) {
+			os.Remove(target)
+			os.MkdirAll(filepath.Join(target, "keep"), 0755)
+		})
+
+		if text, isError := callTool(t, session, "delete_file", map[string]any{"path": "a.txt", "recursive": true}); !isError {
+			t.Fatalf("delete_file succeeded after the file was replaced: %s", text)
+		}
+		if _, err := os.Stat(filepath.Join(target, "keep")); err != nil {
+			t.Errorf("replacement directory was deleted: %v", err)
+		}
+	})
+}
+
+func TestMoveFileChangedWhileConfirming(t *testing.T) {
+	sandbox, root, outside := newTestSandbox(t)
+	session := connectManageTools(t, sandbox, func(string) {
+		// Point the destination directory out of the root
+		os.Symlink(outside, filepath.Join(root, "dest"))
+	})
+
+	text, isError := callTool(t, session, "move_file", map[string]any{"source": "a.txt", "destination": "dest/a.txt"})
+	if !isError {
+		t.Fatalf("move_file succeeded after the destination changed: %s", text)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "a.txt")); err == nil {
+		t.Errorf("file was moved out of the root")
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.txt")); err != nil {
+		t.Errorf("source was lost: %v", err)
+	}
+}
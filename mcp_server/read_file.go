@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings reported by read_files
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingBinary  = "binary"
+)
+
+// maxHashBytes caps the size of the files read_files hashes, so reading a
+// few lines of a huge file doesn't mean reading all of it
+const maxHashBytes = 16 << 20
+
+// readRange selects the part of a file to read. Line numbers start at 1 and
+// zero values mean "from the start" and "up to the end". Offsets count bytes
+// of the file's text as UTF-8.
+type readRange struct {
+	startLine int
+	endLine   int
+	offset    int64
+	length    int64
+}
+
+func (r readRange) validate() error {
+	if r.startLine < 0 || r.endLine < 0 || r.offset < 0 || r.length < 0 {
+		return fmt.Errorf("lines, offset and length can't be negative")
+	}
+	if r.byLines() && (r.offset > 0 || r.length > 0) {
+		return fmt.Errorf("use either a line range or a byte range, not both")
+	}
+	if r.endLine > 0 && r.startLine > r.endLine {
+		return fmt.Errorf("start_line %d is after end_line %d", r.startLine, r.endLine)
+	}
+	return nil
+}
+
+func (r readRange) byLines() bool {
+	return r.startLine > 0 || r.endLine > 0
+}
+
+// readFile reads the selected range of a file, returning at most limit bytes
+// of content. Failures are reported in the result's Error field.
+func readFile(realPath string, rng readRange, limit int) ReadFileResult {
+	var result ReadFileResult
+
+	file, err := os.Open(realPath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if info.IsDir() {
+		result.Error = "is a directory"
+		return result
+	}
+	result.Size = info.Size()
+
+	if result.Size <= maxHashBytes {
+		if result.SHA256, err = fileSHA256(realPath); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	encoding, text, err := decodeText(bufio.NewReader(file))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Encoding = encoding
+	if encoding == encodingBinary {
+		return result
+	}
+
+	var content, marker string
+	if rng.byLines() {
+		content, marker, err = readLines(text, rng, limit)
+	} else {
+		content, marker, err = readBytes(text, rng, limit)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Content = strings.ToValidUTF8(content, "\uFFFD") + marker
+	result.Truncated = marker != ""
+	return result
+}
+
+// decodeText detects the encoding from a byte order mark or the first bytes
+// of the file and returns a reader of the text as UTF-8
+func decodeText(reader *bufio.Reader) (string, io.Reader, error) {
+	bom, _ := reader.Peek(3)
+
+	switch {
+	case bytes.HasPrefix(bom, []byte{0xEF, 0xBB, 0xBF}):
+		_, _ = reader.Discard(3)
+		return encodingUTF8, reader, nil
+	case bytes.HasPrefix(bom, []byte{0xFF, 0xFE}):
+		text, err := decodeUTF16(reader, binary.LittleEndian)
+		return encodingUTF16LE, text, err
+	case bytes.HasPrefix(bom, []byte{0xFE, 0xFF}):
+		text, err := decodeUTF16(reader, binary.BigEndian)
+		return encodingUTF16BE, text, err
+	}
+
+	sniff, _ := reader.Peek(binarySniffLength)
+	if isBinary(sniff) || !validUTF8Prefix(sniff, len(sniff) == binarySniffLength) {
+		return encodingBinary, nil, nil
+	}
+	return encodingUTF8, reader, nil
+}
+
+// decodeUTF16 skips the byte order mark and returns a reader that converts
+// the rest of the file to UTF-8 as it is read, so only the part up to the
+// limit is ever decoded
+func decodeUTF16(reader *bufio.Reader, order binary.ByteOrder) (io.Reader, error) {
+	if _, err := reader.Discard(2); err != nil {
+		return nil, err
+	}
+	return &utf16Reader{src: reader, order: order, pending: -1}, nil
+}
+
+// utf16Reader converts UTF-16 text to UTF-8. Unpaired surrogates become
+// U+FFFD and an odd byte at the end is dropped.
+type utf16Reader struct {
+	src     *bufio.Reader
+	order   binary.ByteOrder
+	pending int    // Code unit read ahead after an unpaired surrogate, -1 if none
+	out     []byte // Converted bytes not returned yet
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		unit, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+
+		char := rune(unit)
+		if utf16.IsSurrogate(char) {
+			next, err := r.next()
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+			char = unicode.ReplacementChar
+			if err == nil {
+				if pair := utf16.DecodeRune(rune(unit), rune(next)); pair != unicode.ReplacementChar {
+					char = pair
+				} else {
+					r.pending = int(next)
+				}
+			}
+		}
+		r.out = utf8.AppendRune(r.out[:0], char)
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// next returns the next code unit, or io.EOF at the end of the text
+func (r *utf16Reader) next() (uint16, error) {
+	if r.pending >= 0 {
+		unit := uint16(r.pending)
+		r.pending = -1
+		return unit, nil
+	}
+
+	var b [2]byte
+	if _, err := io.ReadFull(r.src, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	return r.order.Uint16(b[:]), nil
+}
+
+// validUTF8Prefix reports whether b is valid UTF-8. If b was cut from a
+// longer text, a rune split at the end is allowed.
+func validUTF8Prefix(b []byte, cut bool) bool {
+	if cut {
+		b = trimIncompleteRune(b)
+	}
+	return utf8.Valid(b)
+}
+
+// trimIncompleteRune drops a partial rune left at the end of b by a cut
+func trimIncompleteRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax-1 && len(b) > i; i++ {
+		if utf8.RuneStart(b[len(b)-1-i]) {
+			if !utf8.FullRune(b[len(b)-1-i:]) {
+				return b[:len(b)-1-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// readBytes reads up to limit bytes from the range's offset. The returned
+// marker is non-empty when the content was cut short by the limit.
+func readBytes(text io.Reader, rng readRange, limit int) (string, string, error) {
+	if rng.offset > 0 {
+		if _, err := io.CopyN(io.Discard, text, rng.offset); err != nil && err != io.EOF {
+			return "", "", err
+		}
+	}
+
+	size := int64(limit)
+	capped := true
+	if rng.length > 0 && rng.length <= size {
+		size = rng.length
+		capped = false
+	}
+
+	content, err := io.ReadAll(io.LimitReader(text, size+1))
+	if err != nil {
+		return "", "", err
+	}
+	if int64(len(content)) <= size {
+		return string(content), "", nil
+	}
+
+	content = trimIncompleteRune(content[:size])
+	if !capped {
+		return string(content), "", nil
+	}
+	next := rng.offset + int64(len(content))
+	return string(content), fmt.Sprintf("\n[... truncated at %d bytes, continue with offset=%d]", limit, next), nil
+}
+
+// readLines reads whole lines of the range until limit bytes are reached.
+// A single line longer than limit is cut, and the marker gives the byte
+// offset to read the rest of it from.
+func readLines(text io.Reader, rng readRange, limit int) (string, string, error) {
+	reader := bufio.NewReader(text)
+	var content strings.Builder
+	var offset int64 // Start of the current line in the text
+
+	for line := 1; rng.endLine == 0 || line <= rng.endLine; line++ {
+		s, n, err := readLine(reader, limit+1)
+		if err != nil && err != io.EOF {
+			return "", "", err
+		}
+
+		if line >= rng.startLine && n > 0 {
+			if content.Len()+n > limit {
+				if content.Len() > 0 {
+					return content.String(), fmt.Sprintf("\n[... truncated at %d bytes, continue with start_line=%d]", limit, line), nil
+				}
+				cut := trimIncompleteRune(s[:limit])
+				content.Write(cut)
+				next := offset + int64(len(cut))
+				return content.String(), fmt.Sprintf("\n[... truncated at %d bytes, line %d is longer; continue with offset=%d]", limit, line, next), nil
+			}
+			content.Write(s)
+		}
+
+		offset += int64(n)
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return content.String(), "", nil
+}
+
+// readLine reads the next line and returns up to keep bytes of it together
+// with the line's full length, so a very long line is never held in memory
+func readLine(reader *bufio.Reader, keep int) ([]byte, int, error) {
+	var line []byte
+	n := 0
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		n += len(chunk)
+		if room := keep - len(line); room > 0 {
+			line = append(line, chunk[:min(room, len(chunk))]...)
+		}
+		if err != bufio.ErrBufferFull {
+			return line, n, err
+		}
+	}
+}
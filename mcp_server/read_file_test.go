@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestReadFileUTF16(t *testing.T) {
+	text := "first line\nпривет 😀\n" + strings.Repeat("more text\n", 100000)
+	units := utf16.Encode([]rune(text))
+
+	tests := []struct {
+		name string
+		bom  []byte
+		put  func(b []byte, unit uint16)
+		want string
+	}{
+		{"little endian", []byte{0xFF, 0xFE}, func(b []byte, u uint16) { b[0], b[1] = byte(u), byte(u>>8) }, encodingUTF16LE},
+		{"big endian", []byte{0xFE, 0xFF}, func(b []byte, u uint16) { b[0], b[1] = byte(u>>8), byte(u) }, encodingUTF16BE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := append([]byte(nil), tt.bom...)
+			for _, unit := range units {
+				var b [2]byte
+				tt.put(b[:], unit)
+				content = append(content, b[:]...)
+			}
+			path := filepath.Join(t.TempDir(), "utf16.txt")
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result := readFile(path, readRange{startLine: 2, endLine: 2}, 1024)
+			if result.Error != "" || result.Encoding != tt.want || result.Content != "привет 😀\n" {
+				t.Errorf("line 2 = %+v", result)
+			}
+
+			result = readFile(path, readRange{offset: 6, length: 4}, 1024)
+			if result.Content != "line" {
+				t.Errorf("bytes 6-10 = %q, want %q", result.Content, "line")
+			}
+
+			result = readFile(path, readRange{}, 64)
+			if !result.Truncated || !strings.HasPrefix(result.Content, "first line\nпривет") {
+				t.Errorf("limited read = %+v, want truncated content", result)
+			}
+		})
+	}
+}
+
+func TestReadFileLongLine(t *testing.T) {
+	long := strings.Repeat("é", 150) // 300 bytes
+	path := filepath.Join(t.TempDir(), "long.txt")
+	if err := os.WriteFile(path, []byte("short\n"+long+"\nlast\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The short line fits, the long one is left for the next read
+	result := readFile(path, readRange{startLine: 1}, 100)
+	if result.Content != "short\n\n[... truncated at 100 bytes, continue with start_line=2]" {
+		t.Fatalf("first read = %q", result.Content)
+	}
+
+	// The long line is cut on a rune boundary, and the offset leads to the rest of it
+	result = readFile(path, readRange{startLine: 2}, 101)
+	wantMarker := "\n[... truncated at 101 bytes, line 2 is longer; continue with offset=106]"
+	if result.Content != long[:100]+wantMarker {
+		t.Fatalf("long line read = %q", result.Content)
+	}
+
+	result = readFile(path, readRange{offset: 106}, 1024)
+	if result.Content != long[100:]+"\nlast\n" {
+		t.Errorf("read from the offset = %q, want the rest of the line", result.Content)
+	}
+}
+
+func TestReadFileHashLimit(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.txt")
+	if err := os.WriteFile(small, []byte("text\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result := readFile(small, readRange{}, 1024); result.SHA256 == "" {
+		t.Errorf("small file has no hash")
+	}
+
+	// A sparse file over the limit, so the test doesn't write it all. The
+	// text covers the part sniffed for binary content.
+	large := filepath.Join(dir, "large.txt")
+	file, err := os.Create(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(strings.Repeat("text\n", binarySniffLength/5+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Truncate(maxHashBytes + 1); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	result := readFile(large, readRange{startLine: 1, endLine: 1}, 1024)
+	if result.Error != "" || result.Content != "text\n" {
+		t.Fatalf("readFile() = %+v, want the first line", result)
+	}
+	if result.SHA256 != "" {
+		t.Errorf("file over the limit was hashed")
+	}
+}
+
+func TestReadFilesPerFileRanges(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "a1\na2\na3\n", "b.txt": "b1\nb2\nb3\n"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sandbox, err := NewSandbox([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, output, err := NewFileTools(sandbox).ReadFiles(context.Background(), nil, ReadFilesInput{
+		Files: []ReadFileRequest{
+			{Path: "a.txt", StartLine: 2, EndLine: 2},
+			{Path: "b.txt", Offset: 3, Length: 2},
+			{Path: "a.txt", StartLine: 3, Offset: 1},
+			{Path: "missing.txt"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		content string
+		hasErr  bool
+	}{
+		{"a2\n", false},
+		{"b2", false},
+		{"", true}, // Line and byte ranges can't be combined
+		{"", true},
+	}
+	if len(output.Files) != len(want) {
+		t.Fatalf("got %d results, want %d", len(output.Files), len(want))
+	}
+	for i, w := range want {
+		got := output.Files[i]
+		if got.Content != w.content || (got.Error != "") != w.hasErr {
+			t.Errorf("result %d = %+v, want content %q, error %v", i, got, w.content, w.hasErr)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// fileResourceTemplate matches the file:// URI of any file under the roots
+const fileResourceTemplate = "file:///{+path}"
+
+// Limits for file resources
+const (
+	maxResourceBytes  = 1 << 20 // Larger files can be read in parts with read_files
+	resourcesPageSize = 1000    // Files per resources/list page
+)
+
+// textMIMETypes covers common workspace files the mime package may not know
+var textMIMETypes = map[string]string{
+	".go":   "text/x-go",
+	".md":   "text/markdown",
+	".txt":  "text/plain",
+	".json": "application/json",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".toml": "application/toml",
+	".csv":  "text/csv",
+	".py":   "text/x-python",
+	".sh":   "text/x-shellscript",
+}
+
+// ReadFileResource reads a file resource. Its URI is the file's absolute
+// file:// URI, which must be inside the session's roots.
+func (ft *FileTools) ReadFileResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	uri := req.Params.URI
+
+	sandbox, err := ft.sessionSandbox(ctx, req.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files outside the roots are reported as missing, like files that don't exist
+	path, err := fileURIPath(uri)
+	if err != nil {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	realPath, err := sandbox.Resolve(path)
+	if err != nil {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	info, err := os.Stat(realPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+
+	if info.Size() > maxResourceBytes {
+		return nil, fmt.Errorf("%s is %d bytes, resources are limited to %d; use read_files to read it in parts", uri, info.Size(), maxResourceBytes)
+	}
+	content, err := os.ReadFile(realPath)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := &mcp.ResourceContents{URI: uri, MIMEType: mimeType(realPath)}
+	if !isBinary(content) && utf8.Valid(content) {
+		contents.Text = string(content)
+		if contents.MIMEType == "" {
+			contents.MIMEType = "text/plain"
+		}
+	} else {
+		contents.Blob = content
+		if contents.MIMEType == "" {
+			contents.MIMEType = http.DetectContentType(content)
+		}
+	}
+
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{contents}}, nil
+}
+
+// ListFileResources answers resources/list with the files under the
+// session's roots. The SDK only lists resources added one by one, which
+// can't keep up with files being created and deleted.
+//
+// The list is split into pages. A cursor is the number of files listed on
+// the pages before it, counted in walk order, so files created or deleted
+// between two pages can shift the list by a few entries.
+func (ft *FileTools) ListFileResources(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		listRequest, ok := req.(*mcp.ListResourcesRequest)
+		if method != "resources/list" || !ok {
+			return next(ctx, method, req)
+		}
+
+		sandbox, err := ft.sessionSandbox(ctx, listRequest.Session)
+		if err != nil {
+			return nil, err
+		}
+
+		offset := 0
+		if listRequest.Params != nil && listRequest.Params.Cursor != "" {
+			offset, err = strconv.Atoi(listRequest.Params.Cursor)
+			if err != nil || offset < 0 {
+				return nil, fmt.Errorf("invalid cursor %q", listRequest.Params.Cursor)
+			}
+		}
+
+		result := &mcp.ListResourcesResult{Resources: []*mcp.Resource{}}
+		seen := 0 // Files passed in walk order, including skipped ones
+		for _, root := range sandbox.Roots() {
+			err := walkFiles(ctx, sandbox, root, walkOptions{}, func(path string, rel string) error {
+				// Skip symlinks that point outside the roots
+				realPath, err := sandbox.Resolve(path)
+				if err != nil {
+					return nil
+				}
+				info, err := os.Stat(realPath)
+				if err != nil || !info.Mode().IsRegular() {
+					return nil
+				}
+				seen++
+				if seen <= offset {
+					return nil
+				}
+				if len(result.Resources) >= resourcesPageSize {
+					result.NextCursor = strconv.Itoa(offset + len(result.Resources))
+					return errStopWalk
+				}
+
+				result.Resources = append(result.Resources, &mcp.Resource{
+					URI:      fileURI(path),
+					Name:     sandbox.DisplayPath(path),
+					MIMEType: mimeType(path),
+					Size:     info.Size(),
+				})
+				return nil
+			})
+
+			if errors.Is(err, errStopWalk) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	}
+}
+
+// mimeType guesses a file's MIME type from its extension, "" if unknown
+func mimeType(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if mimeType, ok := textMIMETypes[ext]; ok {
+		return mimeType
+	}
+	return mime.TypeByExtension(ext)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestListFileResourcesPages(t *testing.T) {
+	sandbox, root, _ := newTestSandbox(t)
+	dir := filepath.Join(root, "many")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	const extra = resourcesPageSize + 50
+	for i := range extra {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%04d.txt", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a.txt and sub/b.txt from the test layout are listed too
+	const total = extra + 2
+
+	ft := NewFileTools(sandbox)
+	server := mcp.NewServer(&mcp.Implementation{Name: "test"}, nil)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{Name: "file", URITemplate: fileResourceTemplate}, ft.ReadFileResource)
+	server.AddReceivingMiddleware(ft.ListFileResources)
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil)
+	client.AddRoots(&mcp.Root{URI: fileURI(root)})
+	session := connectInMemory(t, server, client)
+
+	first, err := session.ListResources(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListResources() error = %v", err)
+	}
+	if len(first.Resources) != resourcesPageSize || first.NextCursor == "" {
+		t.Fatalf("first page has %d resources and cursor %q, want %d and a cursor", len(first.Resources), first.NextCursor, resourcesPageSize)
+	}
+
+	seen := map[string]bool{}
+	for resource, err := range session.Resources(context.Background(), nil) {
+		if err != nil {
+			t.Fatalf("Resources() error = %v", err)
+		}
+		if seen[resource.URI] {
+			t.Errorf("%s listed twice", resource.URI)
+		}
+		seen[resource.URI] = true
+	}
+	if len(seen) != total {
+		t.Errorf("listed %d resources over all pages, want %d", len(seen), total)
+	}
+
+	if _, err := session.ListResources(context.Background(), &mcp.ListResourcesParams{Cursor: "not a cursor"}); err == nil {
+		t.Errorf("ListResources() with an invalid cursor succeeded")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// sessionSandboxes caches the sandbox built from each session's client roots
+type sessionSandboxes struct {
+	mu       sync.Mutex
+	sessions map[*mcp.ServerSession]*sessionRoots
+}
+
+type sessionRoots struct {
+	sandbox    *Sandbox // nil until fetched, and after the roots change
+	generation int      // Bumped on every roots/list_changed notification
+}
+
+// sandboxFor returns the sandbox for the session that made the request.
+// Clients that list roots are restricted to where their roots overlap the
+// server's own roots; clients that can't list them get the server's roots.
+func (ft *FileTools) sandboxFor(ctx context.Context, req *mcp.CallToolRequest) (*Sandbox, error) {
+	if req == nil {
+		return ft.sandbox, nil
+	}
+	return ft.sessionSandbox(ctx, req.Session)
+}
+
+// sessionSandbox is sandboxFor for requests other than tool calls
+func (ft *FileTools) sessionSandbox(ctx context.Context, session *mcp.ServerSession) (*Sandbox, error) {
+	if session == nil {
+		return ft.sandbox, nil
+	}
+
+	ft.sessions.mu.Lock()
+	state := ft.sessionRoots(session)
+	sandbox, generation := state.sandbox, state.generation
+	ft.sessions.mu.Unlock()
+	if sandbox != nil {
+		return sandbox, nil
+	}
+
+	sandbox, err := ft.sandboxFromClientRoots(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	// If the roots changed while they were being listed, the result may be
+	// stale: use it for this request, but fetch again for the next one
+	ft.sessions.mu.Lock()
+	if state.generation == generation {
+		state.sandbox = sandbox
+	}
+	ft.sessions.mu.Unlock()
+
+	return sandbox, nil
+}
+
+// sessionRoots returns the roots state of a session, creating it on first
+// use. ft.sessions.mu must be held.
+func (ft *FileTools) sessionRoots(session *mcp.ServerSession) *sessionRoots {
+	if state, ok := ft.sessions.sessions[session]; ok {
+		return state
+	}
+
+	if ft.sessions.sessions == nil {
+		ft.sessions.sessions = map[*mcp.ServerSession]*sessionRoots{}
+	}
+	state := &sessionRoots{}
+	ft.sessions.sessions[session] = state
+
+	// Forget the session once it's gone
+	go func() {
+		session.Wait()
+		ft.forgetSession(session)
+	}()
+	return state
+}
+
+// handleRootsListChanged drops the cached sandbox so the next tool call
+// fetches the new roots
+func (ft *FileTools) handleRootsListChanged(ctx context.Context, req *mcp.RootsListChangedRequest) {
+	ft.sessions.mu.Lock()
+	defer ft.sessions.mu.Unlock()
+
+	state := ft.sessionRoots(req.Session)
+	state.sandbox = nil
+	state.generation++
+}
+
+func (ft *FileTools) forgetSession(session *mcp.ServerSession) {
+	ft.sessions.mu.Lock()
+	defer ft.sessions.mu.Unlock()
+
+	delete(ft.sessions.sessions, session)
+}
+
+func (ft *FileTools) sandboxFromClientRoots(ctx context.Context, session *mcp.ServerSession) (*Sandbox, error) {
+	// Client roots only narrow the server roots, so a client that can't
+	// list them simply gets the server roots
+	result, err := session.ListRoots(ctx, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("Failed to list client roots, using server roots: %v", err)
+		return ft.sandbox, nil
+	}
+	// A client that lists no roots allows no directories; falling back to
+	// the server roots would widen access while a client swaps its roots
+	if len(result.Roots) == 0 {
+		return nil, fmt.Errorf("the client has no roots")
+	}
+
+	// The intersection of both sets of roots: a client root inside a server
+	// root narrows it, a server root inside a client root is kept whole
+	var allowed []string
+	for _, root := range result.Roots {
+		path, err := fileURIPath(root.URI)
+		if err != nil {
+			log.Printf("Ignoring client root %s: %v", root.URI, err)
+			continue
+		}
+		realPath, err := evalSymlinksPartial(filepath.Clean(path))
+		if err != nil {
+			log.Printf("Ignoring client root %s: %v", root.URI, err)
+			continue
+		}
+
+		overlap := ft.sandbox.contains(realPath)
+		if overlap {
+			allowed = appendRoot(allowed, realPath)
+		}
+		for _, serverRoot := range ft.sandbox.Roots() {
+			if isWithin(realPath, serverRoot) {
+				allowed = appendRoot(allowed, serverRoot)
+				overlap = true
+			}
+		}
+		if !overlap {
+			log.Printf("Ignoring client root %s: %v", root.URI, ErrOutsideRoot)
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("none of the client roots overlaps the server roots")
+	}
+
+	return NewSandbox(allowed)
+}
+
+// appendRoot adds root to roots unless it's already there
+func appendRoot(roots []string, root string) []string {
+	if slices.Contains(roots, root) {
+		return roots
+	}
+	return append(roots, root)
+}
+
+// fileURIPath converts a file:// URI to a local path
+func fileURIPath(uri string) (string, error) {
+	parsedURL, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if parsedURL.Scheme != "file" {
+		return "", fmt.Errorf("unsupported scheme %q", parsedURL.Scheme)
+	}
+	if parsedURL.Host != "" && parsedURL.Host != "localhost" {
+		return "", fmt.Errorf("remote host %q", parsedURL.Host)
+	}
+	return filepath.FromSlash(parsedURL.Path), nil
+}
+
+// fileURI converts an absolute local path to a file:// URI
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
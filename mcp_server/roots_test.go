@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connectInMemory connects client to server over an in-memory transport
+func connectInMemory(t *testing.T, server *mcp.Server, client *mcp.Client) *mcp.ClientSession {
+	t.Helper()
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(context.Background(), serverTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+	session, err := client.Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+// connectStatTool serves stat_file for sandbox to a client with the given roots
+func connectStatTool(t *testing.T, sandbox *Sandbox, roots ...string) (*mcp.Client, *mcp.ClientSession) {
+	t.Helper()
+
+	ft := NewFileTools(sandbox)
+	server := mcp.NewServer(&mcp.Implementation{Name: "test"}, &mcp.ServerOptions{RootsListChangedHandler: ft.handleRootsListChanged})
+	mcp.AddTool(server, &mcp.Tool{Name: "stat_file"}, ft.StatFile)
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil)
+	for _, root := range roots {
+		client.AddRoots(&mcp.Root{URI: fileURI(root)})
+	}
+	return client, connectInMemory(t, server, client)
+}
+
+func TestSessionRoots(t *testing.T) {
+	sandbox, root, outside := newTestSandbox(t)
+
+	tests := []struct {
+		name    string
+		roots   []string
+		path    string
+		wantErr string
+	}{
+		{name: "same root", roots: []string{root}, path: "a.txt"},
+		{name: "wider root keeps the server root", roots: []string{filepath.Dir(root)}, path: "a.txt"},
+		{name: "narrower root", roots: []string{filepath.Join(root, "sub")}, path: filepath.Join(root, "sub", "b.txt")},
+		{name: "outside the narrower root", roots: []string{filepath.Join(root, "sub")}, path: filepath.Join(root, "a.txt"), wantErr: "outside"},
+		{name: "no overlap", roots: []string{outside}, path: "a.txt", wantErr: "none of the client roots"},
+		{name: "no roots", path: "a.txt", wantErr: "the client has no roots"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, session := connectStatTool(t, sandbox, tt.roots...)
+
+			text, isError := callTool(t, session, "stat_file", map[string]any{"path": tt.path})
+			if tt.wantErr == "" && isError {
+				t.Errorf("stat_file error = %s", text)
+			}
+			if tt.wantErr != "" && (!isError || !strings.Contains(text, tt.wantErr)) {
+				t.Errorf("stat_file = %s, want an error containing %q", text, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionRootsChangeWhileListing(t *testing.T) {
+	sandbox, root, _ := newTestSandbox(t)
+	sub := filepath.Join(root, "sub")
+
+	// The first roots/list answer is held back until the roots have changed,
+	// so the server receives the old roots after the notification
+	listing := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	client, session := connectStatTool(t, sandbox, sub)
+	client.AddReceivingMiddleware(func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			result, err := next(ctx, method, req)
+			if _, ok := req.(*mcp.ListRootsRequest); ok {
+				once.Do(func() {
+					close(listing)
+					<-release
+				})
+			}
+			return result, err
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		callTool(t, session, "stat_file", map[string]any{"path": filepath.Join(sub, "b.txt")})
+	}()
+
+	<-listing
+	client.AddRoots(&mcp.Root{URI: fileURI(root)})
+	client.RemoveRoots(fileURI(sub))
+	close(release)
+	<-done
+
+	// The stale roots must not be cached: a.txt is inside the new root
+	if text, isError := callTool(t, session, "stat_file", map[string]any{"path": "a.txt"}); isError {
+		t.Errorf("stat_file after the roots changed = %s, want the new roots used", text)
+	}
+}
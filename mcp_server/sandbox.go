@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutsideRoot is returned for paths that resolve outside every allowed root
+var ErrOutsideRoot = errors.New("path is outside the allowed roots")
+
+// Sandbox restricts file access to a set of root directories. Paths are
+// resolved with symlinks followed, so links can't be used to escape a root.
+type Sandbox struct {
+	roots []string // Absolute, symlink-free; the first one is the primary root
+}
+
+// NewSandbox creates a sandbox for the given root directories
+func NewSandbox(roots []string) (*Sandbox, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("at least one root is required")
+	}
+
+	sandbox := &Sandbox{}
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %s: %w", root, err)
+		}
+		realRoot, err := filepath.EvalSymlinks(absRoot)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %s: %w", root, err)
+		}
+		info, err := os.Stat(realRoot)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid root %s: not a directory", root)
+		}
+		sandbox.roots = append(sandbox.roots, realRoot)
+	}
+
+	return sandbox, nil
+}
+
+// Roots returns the allowed root directories
+func (s *Sandbox) Roots() []string {
+	return append([]string(nil), s.roots...)
+}
+
+// Resolve turns a path supplied by the model into an absolute, symlink-free
+// path inside one of the roots. Relative paths are relative to the primary
+// root. The path itself doesn't have to exist yet.
+func (s *Sandbox) Resolve(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("empty path")
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.roots[0], path)
+	}
+
+	realPath, err := evalSymlinksPartial(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+
+	if !s.contains(realPath) {
+		return "", fmt.Errorf("%s: %w", path, ErrOutsideRoot)
+	}
+
+	return realPath, nil
+}
+
+// ResolveEntry is like Resolve, but a symlink in the last path element is not
+// followed, so the link itself can be moved or deleted. Roots themselves are
+// rejected.
+func (s *Sandbox) ResolveEntry(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("empty path")
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.roots[0], path)
+	}
+	path = filepath.Clean(path)
+
+	for _, root := range s.roots {
+		if path == root {
+			return "", fmt.Errorf("%s is a root directory", path)
+		}
+	}
+
+	parent, err := s.Resolve(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(path)), nil
+}
+
+// Rel returns the path relative to the root that contains it
+func (s *Sandbox) Rel(realPath string) string {
+	root := s.rootOf(realPath)
+	if root == "" {
+		return realPath
+	}
+	rel, _ := filepath.Rel(root, realPath)
+	return rel
+}
+
+// DisplayPath returns the path as it is shown to the model: relative to the
+// primary root when inside it, absolute otherwise. Either form is accepted
+// back by Resolve.
+func (s *Sandbox) DisplayPath(realPath string) string {
+	if isWithin(s.roots[0], realPath) {
+		rel, _ := filepath.Rel(s.roots[0], realPath)
+		return rel
+	}
+	return realPath
+}
+
+// rootOf returns the root that contains the path, or "" if there is none
+func (s *Sandbox) rootOf(realPath string) string {
+	for _, root := range s.roots {
+		if isWithin(root, realPath) {
+			return root
+		}
+	}
+	return ""
+}
+
+func (s *Sandbox) contains(realPath string) bool {
+	return s.rootOf(realPath) != ""
+}
+
+func isWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// evalSymlinksPartial resolves symlinks in the longest existing prefix of
+// path and appends the missing rest unchanged
+func evalSymlinksPartial(path string) (string, error) {
+	existing := path
+	var missing []string
+
+	for {
+		realPath, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{realPath}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		// A dangling symlink would be followed when the file is created
+		if _, err := os.Lstat(existing); err == nil {
+			return "", fmt.Errorf("%s is a dangling symlink", existing)
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestSandbox creates this layout and a sandbox for root:
+//
+//	root/a.txt
+//	root/sub/b.txt
+//	root/link_in -> root/sub
+//	root/link_out -> outside
+//	root/dangling -> root/missing
+//	outside/secret.txt
+func newTestSandbox(t *testing.T) (*Sandbox, string, string) {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "outside")
+
+	for _, path := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{filepath.Join(root, "a.txt"), filepath.Join(root, "sub", "b.txt"), filepath.Join(outside, "secret.txt")} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"link_in":  filepath.Join(root, "sub"),
+		"link_out": outside,
+		"dangling": filepath.Join(root, "missing"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sandbox, err := NewSandbox([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sandbox, root, outside
+}
+
+type resolveTest struct {
+	name    string
+	path    string
+	want    string // Relative to the root
+	outside bool   // Fails with ErrOutsideRoot
+	fails   bool   // Fails with another error
+}
+
+func runResolveTests(t *testing.T, resolve func(string) (string, error), root string, tests []resolveTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolve(tt.path)
+
+			switch {
+			case tt.outside:
+				if !errors.Is(err, ErrOutsideRoot) {
+					t.Errorf("got %q, %v; want ErrOutsideRoot", got, err)
+				}
+			case tt.fails:
+				if err == nil || errors.Is(err, ErrOutsideRoot) {
+					t.Errorf("got %q, %v; want an error other than ErrOutsideRoot", got, err)
+				}
+			case err != nil:
+				t.Errorf("error = %v", err)
+			case got != filepath.Join(root, tt.want):
+				t.Errorf("got %q, want %q", got, filepath.Join(root, tt.want))
+			}
+		})
+	}
+}
+
+func TestSandboxResolve(t *testing.T) {
+	sandbox, root, outside := newTestSandbox(t)
+
+	runResolveTests(t, sandbox.Resolve, root, []resolveTest{
+		{name: "relative", path: "a.txt", want: "a.txt"},
+		{name: "absolute inside", path: filepath.Join(root, "sub", "b.txt"), want: "sub/b.txt"},
+		{name: "dot dot inside", path: "sub/../a.txt", want: "a.txt"},
+		{name: "root itself", path: ".", want: "."},
+		{name: "dot dot escape", path: "../outside/secret.txt", outside: true},
+		{name: "nested dot dot escape", path: "sub/../../outside", outside: true},
+		{name: "dot dot through missing directory", path: "missing/../../outside/secret.txt", outside: true},
+		{name: "absolute outside", path: filepath.Join(outside, "secret.txt"), outside: true},
+		{name: "absolute system path", path: "/etc/passwd", outside: true},
+		{name: "symlink inside", path: "link_in/b.txt", want: "sub/b.txt"},
+		{name: "symlink out", path: "link_out", outside: true},
+		{name: "file behind symlink out", path: "link_out/secret.txt", outside: true},
+		{name: "new file behind symlink out", path: "link_out/new.txt", outside: true},
+		{name: "dangling symlink", path: "dangling", fails: true},
+		{name: "missing parent", path: "new/dir/file.txt", want: "new/dir/file.txt"},
+		{name: "empty", path: " ", fails: true},
+	})
+}
+
+func TestSandboxResolveEntry(t *testing.T) {
+	sandbox, root, outside := newTestSandbox(t)
+
+	runResolveTests(t, sandbox.ResolveEntry, root, []resolveTest{
+		{name: "file", path: "sub/b.txt", want: "sub/b.txt"},
+		{name: "symlink out is not followed", path: "link_out", want: "link_out"},
+		{name: "dangling symlink", path: "dangling", want: "dangling"},
+		{name: "parent through symlink", path: "link_in/b.txt", want: "sub/b.txt"},
+		{name: "root", path: ".", fails: true},
+		{name: "absolute root", path: root, fails: true},
+		{name: "root through dot dot", path: "sub/..", fails: true},
+		{name: "dot dot escape", path: "../outside", outside: true},
+		{name: "behind symlink out", path: "link_out/secret.txt", outside: true},
+		{name: "absolute outside", path: filepath.Join(outside, "secret.txt"), outside: true},
+	})
+}
+
+func TestSandboxMultipleRoots(t *testing.T) {
+	_, root, outside := newTestSandbox(t)
+	sandbox, err := NewSandbox([]string{root, outside})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sandbox.Resolve(filepath.Join(outside, "secret.txt"))
+	if err != nil || got != filepath.Join(outside, "secret.txt") {
+		t.Errorf("Resolve() = %q, %v; want the file in the second root", got, err)
+	}
+	if got := sandbox.DisplayPath(filepath.Join(root, "sub", "b.txt")); got != filepath.Join("sub", "b.txt") {
+		t.Errorf("DisplayPath() in the primary root = %q", got)
+	}
+	if got := sandbox.DisplayPath(filepath.Join(outside, "secret.txt")); got != filepath.Join(outside, "secret.txt") {
+		t.Errorf("DisplayPath() in the second root = %q", got)
+	}
+	// A relative path is always relative to the primary root
+	if got, err := sandbox.Resolve("secret.txt"); err != nil || got != filepath.Join(root, "secret.txt") {
+		t.Errorf("Resolve() of a relative path = %q, %v; want it in the primary root", got, err)
+	}
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"serge.com/mcp-example/api/cryptogecko"
@@ -78,132 +80,63 @@ func GetCryptoPrice(ctx context.Context, req *mcp.CallToolRequest, input CryptoP
 	}, nil
 }
 
-const rootPath = "/Users/sergeyusachev/Projects/GoProjects/MCP_Example"
-
-type SearchFilesInput struct {
-	Pattern string `json:"pattern" jsonschema:"File pattern to search for (e.g. *.txt, *.md)"`
-}
-
-type SearchFilesOutput struct {
-	Files []string `json:"files" jsonschema:"List of found file paths"`
-}
-
-type ReadFilesInput struct {
-	Files []string `json:"files" jsonschema:"Array of file paths to read"`
-}
-
-type ReadFilesOutput struct {
-	Text string `json:"text" jsonschema:"Combined text content from all files"`
-}
-
-type SaveToFileInput struct {
-	Filename string `json:"filename" jsonschema:"Name of the file to save (will be created in root directory)"`
-	Text     string `json:"text" jsonschema:"Text content to save to the file"`
-}
-
-type SaveToFileOutput struct {
-	FilePath string `json:"file_path" jsonschema:"Full path to the saved file"`
-	Success  bool   `json:"success" jsonschema:"Whether the save was successful"`
-}
-
-func SearchFiles(ctx context.Context, req *mcp.CallToolRequest, input SearchFilesInput) (
-	*mcp.CallToolResult,
-	SearchFilesOutput,
-	error,
-) {
-	var foundFiles []string
+var (
+	httpAddr    = flag.String("http", "", "serve over streamable HTTP on this address (e.g. :8080) instead of stdin/stdout")
+	bearerToken = flag.String("token", "", "require this bearer token from HTTP clients (defaults to $MCP_SERVER_TOKEN)")
+	roots       = flag.String("roots", "", "directories the file tools may access, separated by the OS path list separator (defaults to $MCP_FS_ROOTS, then the working directory)")
+)
 
-	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		matched, err := filepath.Match(input.Pattern, filepath.Base(path))
-		if err != nil {
-			return err
-		}
-		if matched {
-			foundFiles = append(foundFiles, path)
-		}
-		return nil
-	})
+func main() {
+	flag.Parse()
 
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{Text: fmt.Sprintf("Failed to search files: %v", err)},
-			},
-			IsError: true,
-		}, SearchFilesOutput{}, nil
+	// File tools only see the configured roots
+	rootList := *roots
+	if rootList == "" {
+		rootList = os.Getenv("MCP_FS_ROOTS")
 	}
-
-	return nil, SearchFilesOutput{Files: foundFiles}, nil
-}
-
-func ReadFiles(ctx context.Context, req *mcp.CallToolRequest, input ReadFilesInput) (
-	*mcp.CallToolResult,
-	ReadFilesOutput,
-	error,
-) {
-	var combinedText strings.Builder
-
-	for _, filePath := range input.Files {
-		filePath = strings.TrimSpace(filePath)
-		if filePath == "" {
-			continue
-		}
-
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			return &mcp.CallToolResult{
-				Content: []mcp.Content{
-					&mcp.TextContent{Text: fmt.Sprintf("Failed to read file %s: %v", filePath, err)},
-				},
-				IsError: true,
-			}, ReadFilesOutput{}, nil
-		}
-
-		combinedText.WriteString(fmt.Sprintf("\n=== %s ===\n%s\n", filePath, string(content)))
+	if rootList == "" {
+		rootList = "."
 	}
-
-	return nil, ReadFilesOutput{Text: combinedText.String()}, nil
-}
-
-func SaveToFile(ctx context.Context, req *mcp.CallToolRequest, input SaveToFileInput) (
-	*mcp.CallToolResult,
-	SaveToFileOutput,
-	error,
-) {
-	// Create full file path in root directory
-	filePath := filepath.Join(rootPath, input.Filename)
-
-	// Write content to file
-	err := os.WriteFile(filePath, []byte(input.Text), 0644)
+	sandbox, err := NewSandbox(strings.Split(rootList, string(os.PathListSeparator)))
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				&mcp.TextContent{Text: fmt.Sprintf("Failed to save file: %v", err)},
-			},
-			IsError: true,
-		}, SaveToFileOutput{Success: false}, nil
+		log.Fatal(err)
 	}
+	fileTools := NewFileTools(sandbox)
 
-	return nil, SaveToFileOutput{
-		FilePath: filePath,
-		Success:  true,
-	}, nil
-}
-
-func main() {
 	// Create a server with tools
-	server := mcp.NewServer(&mcp.Implementation{Name: "greeter", Version: "v1.0.0"}, nil)
+	server := mcp.NewServer(&mcp.Implementation{Name: "greeter", Version: "v1.0.0"}, &mcp.ServerOptions{
+		RootsListChangedHandler: fileTools.handleRootsListChanged,
+	})
 	mcp.AddTool(server, &mcp.Tool{Name: "greet", Description: "say hi"}, SayHi)
 	mcp.AddTool(server, &mcp.Tool{Name: "get_crypto_price", Description: "Getter for crypto price"}, GetCryptoPrice)
-	mcp.AddTool(server, &mcp.Tool{Name: "search_files", Description: "Searches files in filesystem"}, SearchFiles)
-	mcp.AddTool(server, &mcp.Tool{Name: "read_files", Description: "Reads files"}, ReadFiles)
-	mcp.AddTool(server, &mcp.Tool{Name: "save_to_file", Description: "Saves text content to a file"}, SaveToFile)
+	mcp.AddTool(server, &mcp.Tool{Name: "search_files", Description: "Searches files in filesystem"}, fileTools.SearchFiles)
+	mcp.AddTool(server, &mcp.Tool{Name: "grep_files", Description: "Searches file contents by regular expression"}, fileTools.GrepFiles)
+	mcp.AddTool(server, &mcp.Tool{Name: "read_files", Description: "Reads files"}, fileTools.ReadFiles)
+	mcp.AddTool(server, &mcp.Tool{Name: "save_to_file", Description: "Saves text content to a file"}, fileTools.SaveToFile)
+	mcp.AddTool(server, &mcp.Tool{Name: "edit_file", Description: "Edits a file with search/replace edits or a unified diff"}, fileTools.EditFile)
+	mcp.AddTool(server, &mcp.Tool{Name: "list_directory", Description: "Lists a directory, optionally as a tree of its subdirectories"}, fileTools.ListDirectory)
+	mcp.AddTool(server, &mcp.Tool{Name: "stat_file", Description: "Returns the type, size, modification time and permissions of a file"}, fileTools.StatFile)
+	mcp.AddTool(server, &mcp.Tool{Name: "move_file", Description: "Moves or renames a file or directory, after the user confirms", Annotations: destructiveTool(false)}, fileTools.MoveFile)
+	mcp.AddTool(server, &mcp.Tool{Name: "copy_file", Description: "Copies a file; replacing an existing file needs the user's confirmation", Annotations: destructiveTool(true)}, fileTools.CopyFile)
+	mcp.AddTool(server, &mcp.Tool{Name: "delete_file", Description: "Deletes a file or directory, after the user confirms", Annotations: destructiveTool(true)}, fileTools.DeleteFile)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{Name: "file", URITemplate: fileResourceTemplate, Description: "Files under the roots"}, fileTools.ReadFileResource)
+	server.AddReceivingMiddleware(fileTools.ListFileResources)
+
+	if *httpAddr != "" {
+		token := *bearerToken
+		if token == "" {
+			token = os.Getenv("MCP_SERVER_TOKEN")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := serveHTTP(ctx, server, *httpAddr, token); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Run the server over stdin/stdout, until the client disconnects.
 	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
 		log.Fatal(err)
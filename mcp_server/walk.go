@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// errStopWalk is returned by a walkFiles callback to end the walk early, once
+// enough results are found
+var errStopWalk = errors.New("stop walk")
+
+// walkOptions controls which files walkFiles visits
+type walkOptions struct {
+	Exclude  []string // Globs for files and directories to skip
+	MaxDepth int      // Directory levels to descend below the start, zero means no limit
+	NoIgnore bool     // Also visit default-ignored directories and .gitignore'd paths
+}
+
+// walkFiles calls fn for every file under start, an absolute path inside the
+// sandbox, together with the file's slash-separated path relative to its
+// root. Excluded and ignored paths are skipped. An error from fn stops the
+// walk and is returned.
+func walkFiles(ctx context.Context, sandbox *Sandbox, start string, opts walkOptions, fn func(path string, rel string) error) error {
+	var ignore ignoreRules
+	if !opts.NoIgnore {
+		loadParentIgnores(&ignore, sandbox, start)
+	}
+
+	return filepath.WalkDir(start, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel := filepath.ToSlash(sandbox.Rel(path))
+		isDir := entry.IsDir()
+
+		// The start itself is never skipped, the caller asked for it
+		if path != start {
+			if skipPath(&ignore, opts, rel, isDir) {
+				if isDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isDir && opts.MaxDepth > 0 && depth(start, path) >= opts.MaxDepth {
+				return filepath.SkipDir
+			}
+		}
+
+		if isDir {
+			if !opts.NoIgnore {
+				ignore.load(path, rel)
+			}
+			return nil
+		}
+
+		return fn(path, rel)
+	})
+}
+
+func skipPath(ignore *ignoreRules, opts walkOptions, rel string, isDir bool) bool {
+	if matchesAnyGlob(opts.Exclude, rel) {
+		return true
+	}
+	if opts.NoIgnore {
+		return false
+	}
+	if isDir && matchesAnyGlob(defaultIgnores, rel) {
+		return true
+	}
+	return ignore.ignored(rel, isDir)
+}
+
+// loadParentIgnores loads the .gitignore files between the root and start,
+// which the walk itself never visits
+func loadParentIgnores(ignore *ignoreRules, sandbox *Sandbox, start string) {
+	root := sandbox.rootOf(start)
+	rel := filepath.ToSlash(sandbox.Rel(start))
+	if root == "" || rel == "." {
+		return
+	}
+
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		dirRel := strings.Join(segments[:i], "/")
+		ignore.load(filepath.Join(root, filepath.FromSlash(dirRel)), dirRel)
+	}
+}
+
+// depth returns how many directory levels path is below start
+func depth(start string, path string) int {
+	rel, err := filepath.Rel(start, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Write modes of save_to_file
+const (
+	writeModeCreate    = "create"    // Fail if the file exists
+	writeModeOverwrite = "overwrite" // Replace the file or create it
+	writeModeAppend    = "append"    // Add to the end of the file or create it
+)
+
+// maxWriteBytes caps the content a single save_to_file call may write
+const maxWriteBytes = 1 << 20
+
+// defaultFileMode is used for files that didn't exist before
+const defaultFileMode = 0644
+
+// ErrHashMismatch is returned when a file no longer has the content the
+// caller based its changes on
+var ErrHashMismatch = errors.New("file was changed since it was read")
+
+// checkFileHash verifies that the file's SHA-256 is the expected one
+func checkFileHash(path string, expected string) error {
+	actual, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: expected sha256 %s, found %s", ErrHashMismatch, expected, actual)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of the file's content
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeFileAtomic writes content to a temporary file next to path and renames
+// it into place, so readers never see a partially written file. With
+// createOnly the file must not exist yet.
+func writeFileAtomic(path string, content io.Reader, createOnly bool) error {
+	mode := os.FileMode(defaultFileMode)
+	if info, err := os.Stat(path); err == nil {
+		if createOnly {
+			return fmt.Errorf("%s: %w", path, os.ErrExist)
+		}
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once the file has been renamed
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+
+	// A hard link fails if the file was created in the meantime, where a
+	// rename would replace it
+	if createOnly {
+		if err := os.Link(tmpPath, path); err != nil {
+			return err
+		}
+		return nil
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// appendFile adds data to the end of the file, creating it if needed
+func appendFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, defaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}